@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"wget/logger"
+)
+
+// childEnv marks the detached copy of the process started by -B.
+const childEnv = "GWAW_BACKGROUND_CHILD"
+
+// setupLog points the log at the file chosen by -o / -a, or moves the whole
+// run into the background for -B. The parent process of a -B run exits here
+// once the detached copy has started.
+func setupLog(flags map[string]string) error {
+	if os.Getenv(childEnv) != "" {
+		// stdout and stderr of the detached copy already are the log
+		logger.SetLogFile(os.Stdout)
+		return writePidFile(flags["pid-file"])
+	}
+
+	path, appendTo := flags["output-file"], false
+	if flags["append-output"] != "" {
+		path, appendTo = flags["append-output"], true
+	}
+
+	if flags["B"] != "" {
+		if path == "" {
+			path = logger.FreeLogName("wget-log")
+		}
+		pidFile := flags["pid-file"]
+		if pidFile == "" {
+			pidFile = path + ".pid"
+		}
+		return detach(path, appendTo, pidFile, flags["log-format"] == "json")
+	}
+
+	if path != "" {
+		file, err := logger.OpenLog(path, appendTo)
+		if err != nil {
+			return err
+		}
+		logger.SetLogFile(file)
+	}
+	return nil
+}
+
+// writePidFile records the pid of the background run and removes the file
+// again when the run ends.
+func writePidFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing pid file: %v", err)
+	}
+	logger.AtExit(func() { os.Remove(path) })
+	return nil
+}
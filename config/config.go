@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"wget/utils"
 )
 
@@ -19,12 +20,86 @@ func ParseFlags() (map[string]string, bool, bool, string, error) {
 		"reject":   flag.String("reject", "", "Alias for -R"),
 		"X":        flag.String("X", "", "Comma separated list of directories to exclude"),
 		"exclude":  flag.String("exclude", "", "Alias for -X"),
+		"tries":    flag.String("tries", "", "Number of attempts per request, 0 for unlimited (default 3)"),
+		"retry-on": flag.String("retry-on", "", "Comma separated retryable failures e.g. 'net,429,5xx'"),
+		"waitretry": flag.String("waitretry", "", "Maximum seconds to wait between retries (default 10)"),
+		"segments": flag.String("segments", "", "Download a single file over N parallel connections"),
+		"jobs":     flag.String("jobs", "", "Maximum parallel downloads for -i (default 4)"),
+		"input-format": flag.String("input-format", "", "Format of the -i file: auto (default), plain, jsonl or csv"),
+		"host-jobs": flag.String("host-jobs", "", "Maximum parallel downloads per host, 0 for no cap (default 4)"),
+		"wait":     flag.String("wait", "", "Seconds to wait between requests to the same host"),
+		"host-rate": flag.String("host-rate", "", "Maximum requests per second to the same host"),
+		"user-agent": flag.String("user-agent", "", "Identify as this User-Agent (default 'get-with-a-w/1.0')"),
+		"referer":  flag.String("referer", "", "Send this Referer header"),
+		"method":   flag.String("method", "", "HTTP method to use for downloads (default GET)"),
+		"post-data": flag.String("post-data", "", "Send this string as the body of a POST request"),
+		"post-file": flag.String("post-file", "", "Send the contents of this file as the body of a POST request"),
+		"body-file": flag.String("body-file", "", "Send the contents of this file as the body of a --method request"),
+		"user":     flag.String("user", "", "User name for HTTP authentication"),
+		"password": flag.String("password", "", "Password for HTTP authentication"),
+		"bearer-token": flag.String("bearer-token", "", "Send 'Authorization: Bearer <token>' to the requested host"),
+		"load-cookies": flag.String("load-cookies", "", "Load cookies from a Netscape cookies.txt file"),
+		"save-cookies": flag.String("save-cookies", "", "Save cookies to a Netscape cookies.txt file"),
+		"proxy":    flag.String("proxy", "", "Proxy URL for all requests e.g. 'http://host:3128' or 'socks5://host:1080'"),
+		"no-proxy": flag.String("no-proxy", "", "Comma separated hosts, domains or CIDRs that bypass the proxy, '*' for all"),
+		"proxy-user": flag.String("proxy-user", "", "User name for proxy authentication"),
+		"proxy-password": flag.String("proxy-password", "", "Password for proxy authentication"),
+		"ca-certificate": flag.String("ca-certificate", "", "PEM file with extra CA certificates to trust"),
+		"ca-directory": flag.String("ca-directory", "", "Directory of PEM CA certificates to trust"),
+		"certificate": flag.String("certificate", "", "Client certificate (PEM) for mutual TLS"),
+		"private-key": flag.String("private-key", "", "Private key (PEM) of --certificate"),
+		"tls-min-version": flag.String("tls-min-version", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3 (default 1.2)"),
+		"pinned-pubkey": flag.String("pinned-pubkey", "", "Require the server key to match 'sha256//<base64>' pins, separated by ';'"),
+		"checksum": flag.String("checksum", "", "Expected checksum of the download e.g. 'sha256:<hex>'"),
+		"checksum-file": flag.String("checksum-file", "", "Verify downloads against a SHA256SUMS style file"),
+		"manifest": flag.String("manifest", "", "Write the sha256 of every downloaded file to this file"),
+		"output-file": flag.String("output-file", "", "Write the log to this file"),
+		"append-output": flag.String("append-output", "", "Append the log to this file"),
+		"pid-file": flag.String("pid-file", "", "With -B, write the pid of the background process here (default <log>.pid)"),
+		"log-format": flag.String("log-format", "", "Log format, 'text' (default) or 'json' for one JSON event per line"),
+		"max-redirect": flag.String("max-redirect", "", "Follow at most N redirects per request (default 10)"),
+		"backups":  flag.String("backups", "", "Keep N older versions of overwritten files as file.1 ... file.N"),
+		"e":        flag.String("e", "", "Execute wgetrc-style commands e.g. 'robots=off'"),
+		"l":        flag.String("l", "", "Maximum recursion depth for --mirror, 'inf' or 0 for unlimited"),
+		"level":    flag.String("level", "", "Alias for -l"),
 	}
+	flag.StringVar(flagSet["user-agent"], "U", "", "Alias for --user-agent")
+	flag.StringVar(flagSet["output-file"], "o", "", "Alias for --output-file")
+	flag.StringVar(flagSet["append-output"], "a", "", "Alias for --append-output")
+	flagHeader := &listFlag{}
+	flag.Var(flagHeader, "header", "Add a 'Name: value' header to every request (repeatable)")
 	flagB := flag.Bool("B", false, "Log output to wget-log")
 	flagMirror := flag.Bool("mirror", false, "Mirror the entire website")
 	flagHelp := flag.Bool("help", false, "Display help information")
 	flagWeb := flag.Bool("web", false, "Start the web server interface")
+	flagConfig := flag.String("config", "", "Read options from this TOML config file")
 	flagConvert := flag.Bool("convert-links", false, "Convert links to local")
+	flagAskPassword := flag.Bool("ask-password", false, "Prompt for the --user password")
+	flagNoClobber := flag.Bool("no-clobber", false, "Skip downloads that would overwrite an existing file")
+	flag.BoolVar(flagNoClobber, "nc", false, "Alias for --no-clobber")
+	flagTimestamping := flag.Bool("N", false, "Only download files newer than the local copy")
+	flag.BoolVar(flagTimestamping, "timestamping", false, "Alias for -N")
+	flagDisposition := flag.Bool("content-disposition", false, "Name files after the server's Content-Disposition header")
+	flagAdjustExt := flag.Bool("adjust-extension", false, "Add the extension matching the Content-Type to file names")
+	flag.BoolVar(flagAdjustExt, "E", false, "Alias for --adjust-extension")
+	flagNoRedirect := flag.Bool("no-redirect", false, "Don't follow redirects")
+	flagTrustNames := flag.Bool("trust-server-names", false, "Name files after the URL at the end of the redirects")
+	flagDowngrade := flag.Bool("allow-https-downgrade", false, "Follow redirects from https to http")
+	flagQuiet := flag.Bool("quiet", false, "Print nothing")
+	flag.BoolVar(flagQuiet, "q", false, "Alias for --quiet")
+	flagNoVerbose := flag.Bool("no-verbose", false, "Print one line per file and errors only")
+	flag.BoolVar(flagNoVerbose, "nv", false, "Alias for --no-verbose")
+	flagVerbose := flag.Bool("verbose", false, "Print more details")
+	flag.BoolVar(flagVerbose, "v", false, "Alias for --verbose")
+	flagDebug := flag.Bool("debug", false, "Print debug output, including request and response headers")
+	flag.BoolVar(flagDebug, "d", false, "Alias for --debug")
+	flagNoCheckCert := flag.Bool("no-check-certificate", false, "Don't verify the server certificate")
+	flagKeepSession := flag.Bool("keep-session-cookies", false, "Also save session cookies with --save-cookies")
+	flagRandomWait := flag.Bool("random-wait", false, "Vary --wait between 0.5 and 1.5 times its value")
+	flagSpanHosts := flag.Bool("span-hosts", false, "Let --mirror follow links to other hosts")
+	flag.BoolVar(flagSpanHosts, "H", false, "Alias for --span-hosts")
+	flagContinue := flag.Bool("c", false, "Continue getting a partially-downloaded file")
+	flag.BoolVar(flagContinue, "continue", false, "Alias for -c")
 
 	flag.Parse()
 
@@ -33,6 +108,12 @@ func ParseFlags() (map[string]string, bool, bool, string, error) {
 		os.Exit(0)
 	}
 
+	// options missing on the command line come from config files and env
+	fromConfig, err := applyConfigLayers(*flagConfig)
+	if err != nil {
+		return nil, false, false, "", err
+	}
+
 	flagsUsed := make(map[string]string)
 	urlArg := flag.Arg(0)
 	anyUsed := false
@@ -44,6 +125,11 @@ func ParseFlags() (map[string]string, bool, bool, string, error) {
 		}
 	}
 
+	if len(*flagHeader) > 0 {
+		flagsUsed["header"] = flagHeader.String()
+		anyUsed = true
+	}
+
 	if *flagB {
 		flagsUsed["B"] = "wget-log"
 		anyUsed = true
@@ -56,25 +142,195 @@ func ParseFlags() (map[string]string, bool, bool, string, error) {
 		flagsUsed["convertLinks"] = "true"
 		anyUsed = true
 	}
+	if *flagAskPassword {
+		flagsUsed["ask-password"] = "true"
+		anyUsed = true
+	}
+	if *flagNoClobber {
+		flagsUsed["no-clobber"] = "true"
+		anyUsed = true
+	}
+	if *flagTimestamping {
+		flagsUsed["timestamping"] = "true"
+		anyUsed = true
+	}
+	if *flagDisposition {
+		flagsUsed["content-disposition"] = "true"
+		anyUsed = true
+	}
+	if *flagAdjustExt {
+		flagsUsed["adjust-extension"] = "true"
+		anyUsed = true
+	}
+	if *flagNoRedirect {
+		flagsUsed["no-redirect"] = "true"
+		anyUsed = true
+	}
+	if *flagTrustNames {
+		flagsUsed["trust-server-names"] = "true"
+		anyUsed = true
+	}
+	if *flagDowngrade {
+		flagsUsed["allow-https-downgrade"] = "true"
+		anyUsed = true
+	}
+	for key, set := range map[string]bool{
+		"quiet": *flagQuiet, "no-verbose": *flagNoVerbose, "verbose": *flagVerbose, "debug": *flagDebug,
+	} {
+		if set {
+			flagsUsed[key] = "true"
+			anyUsed = true
+		}
+	}
+	if *flagNoCheckCert {
+		flagsUsed["no-check-certificate"] = "true"
+		anyUsed = true
+	}
+	if *flagKeepSession {
+		flagsUsed["keep-session-cookies"] = "true"
+		anyUsed = true
+	}
+	if *flagRandomWait {
+		flagsUsed["random-wait"] = "true"
+		anyUsed = true
+	}
+	if *flagSpanHosts {
+		flagsUsed["span-hosts"] = "true"
+		anyUsed = true
+	}
+	if *flagContinue {
+		flagsUsed["continue"] = "true"
+		anyUsed = true
+	}
+
+	// -e commands become the options they stand for
+	if err := executeCommands(flagsUsed, fromConfig); err != nil {
+		return nil, false, false, "", err
+	}
+	if err := checkConflicts(flagsUsed, fromConfig); err != nil {
+		return nil, false, false, "", err
+	}
+
+	return flagsUsed, anyUsed, *flagWeb, urlArg, nil
+}
+
+// checkConflicts rejects options that can't be used together. Config files
+// and GWAW_* variables only supply defaults: an option from them gives way
+// to a conflicting one from the command line, and the options of --mirror
+// are ignored in other runs. fromConfig holds the options they set.
+func checkConflicts(flags map[string]string, fromConfig map[string]bool) error {
+	// exclusive drops a from flags when it comes from a config layer and b
+	// doesn't, and reports whether a conflict remains
+	exclusive := func(a, b string) bool {
+		switch {
+		case fromConfig[a] && !fromConfig[b]:
+			delete(flags, a)
+		case fromConfig[b] && !fromConfig[a]:
+			delete(flags, b)
+		default:
+			return true
+		}
+		return false
+	}
 
 	// validation for mutually exclusive flags
 	conflicts := [][2]string{
-		{"i", "O"}, {"i", "P"}, {"i", "B"}, {"i", "rate-limit"},
-		{"R", "reject"}, {"X", "exclude"}, {"mirror", "O"},
-		{"mirror", "i"}, {"mirror", "P"}, {"mirror", "B"},
-		{"mirror", "rate-limit"},
+		{"i", "O"}, {"i", "P"},
+		{"R", "reject"}, {"X", "exclude"}, {"l", "level"}, {"mirror", "O"},
+		{"no-clobber", "backups"}, {"no-clobber", "continue"},
+		{"no-clobber", "timestamping"}, {"no-redirect", "max-redirect"},
+		{"i", "checksum"}, {"mirror", "checksum"},
+		{"password", "ask-password"}, {"user", "bearer-token"},
+		{"post-data", "post-file"}, {"post-data", "body-file"}, {"post-file", "body-file"},
+		{"mirror", "i"}, {"mirror", "P"},
+		{"output-file", "append-output"}, {"B", "ask-password"},
 	}
 	for _, pair := range conflicts {
-		if flagsUsed[pair[0]] != "" && flagsUsed[pair[1]] != "" {
-			return nil, false, false, "", fmt.Errorf("cannot specify both -%s and -%s", pair[0], pair[1])
+		if flags[pair[0]] != "" && flags[pair[1]] != "" && exclusive(pair[0], pair[1]) {
+			return fmt.Errorf("cannot specify both -%s and -%s", pair[0], pair[1])
 		}
 	}
 
-	if (flagsUsed["R"] != "" || flagsUsed["reject"] != "") &&
-		(flagsUsed["X"] != "" || flagsUsed["exclude"] != "") &&
-		flagsUsed["mirror"] == "" {
-		return nil, false, false, "", fmt.Errorf("cannot use -reject or -exclude without -mirror")
+	// stdout and stdin aren't there in the background
+	for _, std := range []string{"O", "i"} {
+		if flags[std] == "-" && flags["B"] != "" && exclusive("B", std) {
+			return fmt.Errorf("cannot use -B with -O - or -i -")
+		}
+	}
+	for _, name := range []string{"continue", "timestamping"} {
+		if flags["O"] == "-" && flags[name] != "" && exclusive(name, "O") {
+			return fmt.Errorf("cannot use -continue or -N with -O -")
+		}
 	}
 
-	return flagsUsed, anyUsed, *flagWeb, urlArg, nil
+	if flags["mirror"] == "" {
+		for _, name := range []string{"R", "reject", "X", "exclude", "l", "level", "span-hosts"} {
+			if fromConfig[name] {
+				delete(flags, name)
+			}
+		}
+	}
+
+	if (flags["R"] != "" || flags["reject"] != "") &&
+		(flags["X"] != "" || flags["exclude"] != "") &&
+		flags["mirror"] == "" {
+		return fmt.Errorf("cannot use -reject or -exclude without -mirror")
+	}
+
+	if (flags["l"] != "" || flags["level"] != "" || flags["span-hosts"] != "") &&
+		flags["mirror"] == "" {
+		return fmt.Errorf("cannot use -level or -span-hosts without -mirror")
+	}
+	return nil
+}
+
+// executeCommands checks the wgetrc-style "name=value" commands given with
+// -e, separated by commas or newlines, and sets the options they name:
+// "robots" for --mirror and "user-agent" like -U. Like other options, a
+// command from a config layer gives way to the command line.
+func executeCommands(flags map[string]string, fromConfig map[string]bool) error {
+	for _, command := range strings.FieldsFunc(flags["e"], func(r rune) bool { return r == ',' || r == '\n' }) {
+		name, value, ok := strings.Cut(command, "=")
+		if !ok {
+			return fmt.Errorf("invalid -e command %q, expected name=value", command)
+		}
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+		value = strings.TrimSpace(value)
+		switch name {
+		case "robots":
+			switch strings.ToLower(value) {
+			case "on", "yes", "true", "1":
+				flags["robots"] = "on"
+			case "off", "no", "false", "0":
+				flags["robots"] = "off"
+			default:
+				return fmt.Errorf("invalid value %q for robots, expected on or off", value)
+			}
+		case "user_agent":
+			if flags["user-agent"] != "" && flags["user-agent"] != value {
+				if fromConfig["e"] == fromConfig["user-agent"] {
+					return fmt.Errorf("cannot specify both -U and -e user_agent")
+				}
+				if fromConfig["e"] {
+					continue // -U on the command line wins
+				}
+			}
+			flags["user-agent"] = value
+		default:
+			return fmt.Errorf("unknown -e command %q", name)
+		}
+	}
+	return nil
+}
+
+// listFlag collects every value of a flag that may be repeated, e.g. --header.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, "\n")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parseWith runs ParseFlags on args with config as the user config file.
+func parseWith(t *testing.T, config string, args ...string) (map[string]string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.MkdirAll(filepath.Join(dir, "get-with-a-w"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "get-with-a-w", "config.toml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	origArgs, origFlags, origSections := os.Args, flag.CommandLine, sections
+	t.Cleanup(func() { os.Args, flag.CommandLine, sections = origArgs, origFlags, origSections })
+	os.Args = append([]string{"get-with-a-w"}, args...)
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	sections = map[string]map[string]any{}
+
+	flags, _, _, _, err := ParseFlags()
+	return flags, err
+}
+
+func TestConfigGivesWayToCommandLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		want    map[string]string // "" means unset
+		wantErr string
+	}{
+		{
+			name:   "mirror options outside of a mirror",
+			config: "level = 3\nspan-hosts = true\nreject = [\"jpg\"]\nexclude = \"/tmp\"\n",
+			args:   []string{"http://example.com/a.zip"},
+			want:   map[string]string{"level": "", "span-hosts": "", "reject": "", "exclude": ""},
+		},
+		{
+			name:   "mirror options in a mirror",
+			config: "level = 3\nreject = [\"jpg\", \"gif\"]\n",
+			args:   []string{"--mirror", "http://example.com/"},
+			want:   map[string]string{"level": "3", "reject": "jpg,gif"},
+		},
+		{
+			name:   "command line wins a conflict",
+			config: "no-clobber = true\ncontinue = true\n",
+			args:   []string{"--backups", "2", "http://example.com/a.zip"},
+			want:   map[string]string{"backups": "2", "no-clobber": "", "continue": "true"},
+		},
+		{
+			name:   "short alias on the command line",
+			config: "timestamping = true\n",
+			args:   []string{"-nc", "http://example.com/a.zip"},
+			want:   map[string]string{"no-clobber": "true", "timestamping": ""},
+		},
+		{
+			name:   "stdout on the command line",
+			config: "continue = true\n",
+			args:   []string{"-O", "-", "http://example.com/a.zip"},
+			want:   map[string]string{"O": "-", "continue": ""},
+		},
+		{
+			name:   "-U wins over a configured -e user_agent",
+			config: "e = \"user_agent=from-config\"\n",
+			args:   []string{"-U", "cli", "http://example.com/a.zip"},
+			want:   map[string]string{"user-agent": "cli"},
+		},
+		{
+			name:    "conflict on the command line",
+			args:    []string{"-nc", "--backups", "2", "http://example.com/a.zip"},
+			wantErr: "cannot specify both -no-clobber and -backups",
+		},
+		{
+			name:    "conflict within the config",
+			config:  "no-clobber = true\nbackups = 2\n",
+			args:    []string{"http://example.com/a.zip"},
+			wantErr: "cannot specify both -no-clobber and -backups",
+		},
+		{
+			name:    "mirror options on the command line",
+			args:    []string{"-l", "2", "http://example.com/"},
+			wantErr: "cannot use -level or -span-hosts without -mirror",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := parseWith(t, tt.config, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseFlags() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if flags[name] != want {
+					t.Errorf("flags[%q] = %q, want %q", name, flags[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      map[string]string
+		fromConfig []string
+		want       string // remaining flags, sorted
+		wantErr    bool
+	}{
+		{"no conflict", map[string]string{"O": "x", "tries": "2"}, nil, "O tries", false},
+		{"both given", map[string]string{"i": "list", "O": "x"}, nil, "", true},
+		{"configured side dropped", map[string]string{"i": "list", "O": "x"}, []string{"O"}, "i", false},
+		{"both configured", map[string]string{"i": "list", "O": "x"}, []string{"i", "O"}, "", true},
+		{"background and stdin", map[string]string{"B": "wget-log", "i": "-"}, nil, "", true},
+		{"configured background and stdin", map[string]string{"B": "wget-log", "i": "-"}, []string{"B"}, "i", false},
+		{"configured mirror overridden", map[string]string{"mirror": "mirror", "O": "x", "level": "2"}, []string{"mirror", "level"}, "O", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromConfig := map[string]bool{}
+			for _, name := range tt.fromConfig {
+				fromConfig[name] = true
+			}
+			err := checkConflicts(tt.flags, fromConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkConflicts() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var names []string
+			for name := range tt.flags {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("remaining flags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+// layered configuration: system file, user file, --config, environment
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// systemConfig is the machine wide config file, read first.
+const systemConfig = "/etc/get-with-a-w/config.toml"
+
+// envPrefix prefixes the environment variables mirroring each flag, e.g.
+// GWAW_RATE_LIMIT=500k for --rate-limit.
+const envPrefix = "GWAW_"
+
+// aliases groups flags that set the same option, so a value from a config
+// layer doesn't clash with the alias given on the command line.
+var aliases = [][]string{
+	{"R", "reject"}, {"X", "exclude"}, {"l", "level"},
+	{"c", "continue"}, {"H", "span-hosts"}, {"U", "user-agent"},
+	{"nc", "no-clobber"}, {"N", "timestamping"}, {"E", "adjust-extension"},
+	{"o", "output-file"}, {"a", "append-output"},
+	{"q", "quiet"}, {"nv", "no-verbose"}, {"v", "verbose"}, {"d", "debug"},
+}
+
+// notConfigurable are flags that only make sense on the command line.
+var notConfigurable = map[string]bool{"help": true, "config": true, "web": true}
+
+// sections holds the TOML tables of the config files, merged by layer, for
+// options that don't map to a single flag.
+var sections = map[string]map[string]any{}
+
+// Section returns the merged [name] table of the config files, or nil.
+func Section(name string) map[string]any {
+	return sections[name]
+}
+
+// userConfigPath returns ~/.config/get-with-a-w/config.toml, honoring
+// XDG_CONFIG_HOME.
+func userConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "get-with-a-w", "config.toml")
+}
+
+// withAliases returns name and the other flags setting the same option.
+func withAliases(name string) []string {
+	for _, group := range aliases {
+		for _, alias := range group {
+			if alias == name {
+				return group
+			}
+		}
+	}
+	return []string{name}
+}
+
+// applyConfigLayers fills every flag not given on the command line from the
+// config files and the environment. Later layers win: system file, user
+// file, the --config file, then GWAW_* variables. It returns the flags it
+// set, aliases included, so command line options can take precedence over
+// them, see checkConflicts.
+func applyConfigLayers(explicit string) (map[string]bool, error) {
+	onCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		for _, alias := range withAliases(f.Name) {
+			onCommandLine[alias] = true
+		}
+	})
+
+	values := map[string][]string{}
+	for _, path := range []string{systemConfig, userConfigPath(), explicit} {
+		if path == "" {
+			continue
+		}
+		if err := loadConfigFile(path, values, path == explicit); err != nil {
+			return nil, err
+		}
+	}
+
+	envFlags, err := envNames()
+	if err != nil {
+		return nil, err
+	}
+	for env, name := range envFlags {
+		if value, ok := os.LookupEnv(env); ok {
+			values[name] = []string{value}
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	applied := map[string]bool{}
+	for _, name := range names {
+		if onCommandLine[name] {
+			continue
+		}
+		items := values[name]
+		if _, repeatable := flag.Lookup(name).Value.(*listFlag); !repeatable {
+			items = []string{strings.Join(items, ",")}
+		}
+		for _, item := range items {
+			if err := flag.Set(name, item); err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+			}
+		}
+		for _, alias := range withAliases(name) {
+			applied[alias] = true
+		}
+	}
+	return applied, nil
+}
+
+// envNames maps every GWAW_* variable to the flag it sets. Short aliases are
+// left to their long name, so GWAW_O is -O while -o is GWAW_OUTPUT_FILE, and
+// two flags ending up with the same variable are an error.
+func envNames() (map[string]string, error) {
+	short := map[string]bool{}
+	for _, group := range aliases {
+		long := group[0]
+		for _, name := range group {
+			if len(name) > len(long) {
+				long = name
+			}
+		}
+		for _, name := range group {
+			short[name] = name != long
+		}
+	}
+
+	names := map[string]string{}
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if notConfigurable[f.Name] || short[f.Name] {
+			return
+		}
+		env := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if other, taken := names[env]; taken && err == nil {
+			err = fmt.Errorf("%s would set both -%s and -%s", env, other, f.Name)
+		}
+		names[env] = f.Name
+	})
+	return names, err
+}
+
+// loadConfigFile merges the options of one TOML file into values. Missing
+// files are skipped unless required is set (the --config path).
+func loadConfigFile(path string, values map[string][]string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return fmt.Errorf("reading config %s: %v", path, err)
+	}
+
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing config %s: %v", path, err)
+	}
+
+	for key, raw := range doc {
+		if table, ok := raw.(map[string]any); ok {
+			if sections[key] == nil {
+				sections[key] = map[string]any{}
+			}
+			for k, v := range table {
+				sections[key][k] = v
+			}
+			continue
+		}
+
+		name := strings.ReplaceAll(key, "_", "-")
+		if flag.Lookup(name) == nil || notConfigurable[name] {
+			return fmt.Errorf("unknown option %q in %s", key, path)
+		}
+		items, err := configValues(raw)
+		if err != nil {
+			return fmt.Errorf("option %q in %s: %v", key, path, err)
+		}
+		values[name] = items
+	}
+	return nil
+}
+
+// configValues turns a TOML value into flag values. An array sets a
+// repeatable flag once per item and becomes a comma separated list, like
+// -R jpg,gif, for the others.
+func configValues(raw any) ([]string, error) {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, nil
+	case bool, int64, float64:
+		return []string{fmt.Sprint(v)}, nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			values, err := configValues(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, values...)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unsupported value type %T", raw)
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import (
+	"wget/logger"
+)
+
+// detach can't leave the terminal on this platform, so the run stays in the
+// foreground and only its output goes to the log.
+func detach(logPath string, appendTo bool, pidFile string, jsonLog bool) error {
+	logFile, err := logger.OpenLog(logPath, appendTo)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Output will be written to '%s'.\n", logPath)
+	logger.SetLogFile(logFile)
+	return writePidFile(pidFile)
+}
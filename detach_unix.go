@@ -0,0 +1,53 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"wget/logger"
+)
+
+// detach starts a copy of this run in a new session, with no terminal and
+// its output going to the log at logPath, and exits.
+func detach(logPath string, appendTo bool, pidFile string, jsonLog bool) error {
+	logFile, err := logger.OpenLog(logPath, appendTo)
+	if err != nil {
+		return err
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), childEnv+"=1")
+	if pidFile != "" {
+		// read back by the config layer as --pid-file
+		cmd.Env = append(cmd.Env, "GWAW_PID_FILE="+pidFile)
+	}
+	cmd.Stdin = devNull
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if jsonLog {
+		// keep the log to JSON events only
+		cmd.Stderr = devNull
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting background process: %v", err)
+	}
+
+	logger.Printf("Continuing in background, pid %d.\n", cmd.Process.Pid)
+	logger.Printf("Output will be written to '%s'.\n", logPath)
+	cmd.Process.Release()
+	os.Exit(0)
+	return nil
+}
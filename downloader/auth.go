@@ -0,0 +1,318 @@
+package downloader
+
+// HTTP authentication - Basic, Digest, bearer tokens and ~/.netrc
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+var (
+	// authUser and authPassword are set by --user / --password
+	authUser     string
+	authPassword string
+	// bearerToken is sent as "Authorization: Bearer" to the host the user
+	// asked for, never to the other hosts of a mirror or redirect
+	bearerToken string
+	// netrcEntries are the machines of ~/.netrc, loaded lazily
+	netrcOnce    sync.Once
+	netrcEntries map[string]netrcEntry
+)
+
+// netrcEntry is one "machine" (or "default") block of a .netrc file.
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// digestState remembers the last Digest challenge of a host so later requests
+// can authenticate without another 401 round trip.
+type digestState struct {
+	params map[string]string
+	nc     int
+}
+
+var (
+	challengeMu sync.Mutex
+	basicHosts  = map[string]bool{}
+	digestHosts = map[string]*digestState{}
+)
+
+// configureAuth applies the authentication flags.
+func configureAuth(flags map[string]string) error {
+	authUser = flags["user"]
+	authPassword = flags["password"]
+	bearerToken = flags["bearer-token"]
+
+	if flags["ask-password"] != "" {
+		if authUser == "" {
+			return fmt.Errorf("--ask-password requires --user")
+		}
+		fmt.Fprintf(os.Stderr, "Password for user '%s': ", authUser)
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("reading password: %v", err)
+		}
+		authPassword = string(password)
+	}
+	return nil
+}
+
+// credentialsFor returns the user and password to use for host. Credentials
+// from the command line only go to origin, the host the user asked for, while
+// .netrc entries are matched by host.
+func credentialsFor(host, origin string) (string, string, bool) {
+	if authUser != "" && host == origin {
+		return authUser, authPassword, true
+	}
+	netrcOnce.Do(loadNetrc)
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	if entry, ok := netrcEntries[name]; ok {
+		return entry.login, entry.password, true
+	}
+	if entry, ok := netrcEntries[""]; ok {
+		return entry.login, entry.password, true
+	}
+	return "", "", false
+}
+
+// loadNetrc parses $NETRC or ~/.netrc. A missing file is not an error.
+func loadNetrc() {
+	netrcEntries = map[string]netrcEntry{}
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	netrcEntries = parseNetrc(file)
+}
+
+// parseNetrc reads the machine blocks of a .netrc file. The "default" block
+// is stored under the empty name.
+func parseNetrc(r io.Reader) map[string]netrcEntry {
+	entries := map[string]netrcEntry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	var (
+		machine string
+		entry   netrcEntry
+		open    bool
+	)
+	flush := func() {
+		if open {
+			entries[machine] = entry
+		}
+	}
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			flush()
+			scanner.Scan()
+			machine, entry, open = scanner.Text(), netrcEntry{}, true
+		case "default":
+			flush()
+			machine, entry, open = "", netrcEntry{}, true
+		case "login":
+			scanner.Scan()
+			entry.login = scanner.Text()
+		case "password":
+			scanner.Scan()
+			entry.password = scanner.Text()
+		case "account":
+			scanner.Scan()
+		case "macdef":
+			// macros run until an empty line, which ScanWords can't see;
+			// stop here rather than misreading them as entries
+			flush()
+			return entries
+		}
+	}
+	flush()
+	return entries
+}
+
+// applyAuth adds credentials to a request when they are known up front: a
+// bearer token, or a host that already challenged us for Basic or Digest.
+func applyAuth(req *http.Request, origin string) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+	if bearerToken != "" && req.URL.Host == origin {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+		return
+	}
+	user, password, ok := credentialsFor(req.URL.Host, origin)
+	if !ok {
+		return
+	}
+
+	challengeMu.Lock()
+	defer challengeMu.Unlock()
+	if state, ok := digestHosts[req.URL.Host]; ok {
+		state.nc++
+		req.Header.Set("Authorization", digestAuthorization(req, user, password, state.params, state.nc))
+	} else if basicHosts[req.URL.Host] {
+		req.SetBasicAuth(user, password)
+	}
+}
+
+// answerChallenge builds an authenticated copy of req after a 401 response,
+// or returns nil when there is no usable challenge or no credentials.
+func answerChallenge(req *http.Request, resp *http.Response, origin string) *http.Request {
+	if req.Header.Get("Authorization") != "" && resp.Request.URL.Host == req.URL.Host {
+		// the credentials we sent were rejected, unless the nonce went stale
+		if !strings.Contains(strings.ToLower(resp.Header.Get("WWW-Authenticate")), "stale=true") {
+			return nil
+		}
+	}
+	target := resp.Request
+	user, password, ok := credentialsFor(target.URL.Host, origin)
+	if !ok {
+		return nil
+	}
+
+	authed := target.Clone(target.Context())
+	if target.GetBody != nil {
+		body, err := target.GetBody()
+		if err != nil {
+			return nil
+		}
+		authed.Body = body
+	}
+
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+		switch strings.ToLower(scheme) {
+		case "digest":
+			params := parseChallenge(rest)
+			challengeMu.Lock()
+			digestHosts[target.URL.Host] = &digestState{params: params, nc: 1}
+			challengeMu.Unlock()
+			authed.Header.Set("Authorization", digestAuthorization(authed, user, password, params, 1))
+			return authed
+		case "basic":
+			challengeMu.Lock()
+			basicHosts[target.URL.Host] = true
+			challengeMu.Unlock()
+			authed.SetBasicAuth(user, password)
+			return authed
+		}
+	}
+	return nil
+}
+
+// parseChallenge parses the comma separated key="value" list of a challenge.
+func parseChallenge(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " ")
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			value = strings.ReplaceAll(rest[1:min(end, len(rest))], `\`, "")
+			s = rest[min(end+1, len(rest)):]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// newCnonce returns a fresh client nonce for a Digest response.
+var newCnonce = func() string {
+	cnonce := make([]byte, 8)
+	rand.Read(cnonce)
+	return hex.EncodeToString(cnonce)
+}
+
+// digestAuthorization computes an RFC 7616 Digest Authorization header.
+func digestAuthorization(req *http.Request, user, password string, params map[string]string, nc int) string {
+	algorithm := params["algorithm"]
+	var newHash func() hash.Hash = md5.New
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	cnonce := newCnonce()
+	uri := req.URL.RequestURI()
+	realm, nonce := params["realm"], params["nonce"]
+
+	ha1 := h(user + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	ncValue := fmt.Sprintf("%08x", nc)
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, nonce, ncValue, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		user, realm, nonce, uri, response)
+	if algorithm != "" {
+		header += ", algorithm=" + algorithm
+	}
+	if qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, ncValue, cnonce)
+	}
+	if opaque, ok := params["opaque"]; ok {
+		header += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return header
+}
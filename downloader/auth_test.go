@@ -0,0 +1,212 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{
+			`realm="test", nonce="abc", qop="auth,auth-int"`,
+			map[string]string{"realm": "test", "nonce": "abc", "qop": "auth,auth-int"},
+		},
+		{
+			`Realm="a, b", algorithm=MD5, stale=false`,
+			map[string]string{"realm": "a, b", "algorithm": "MD5", "stale": "false"},
+		},
+		{
+			`realm="say \"hi\"", opaque=""`,
+			map[string]string{"realm": `say "hi"`, "opaque": ""},
+		},
+		{
+			`realm="unterminated`,
+			map[string]string{"realm": "unterminated"},
+		},
+		{"", map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := parseChallenge(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseChallenge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		cnonce   string
+		params   map[string]string
+		want     string
+	}{
+		{
+			// RFC 7616 section 3.9.1
+			name: "RFC 7616 MD5", user: "Mufasa", password: "Circle of Life",
+			cnonce: "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+			params: map[string]string{
+				"realm": "http-auth@example.org", "qop": "auth, auth-int", "algorithm": "MD5",
+				"nonce":  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+				"opaque": "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			},
+			want: "8ca523f5e9506fed4657c9700eebdbec",
+		},
+		{
+			name: "RFC 7616 SHA-256", user: "Mufasa", password: "Circle of Life",
+			cnonce: "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+			params: map[string]string{
+				"realm": "http-auth@example.org", "qop": "auth, auth-int", "algorithm": "SHA-256",
+				"nonce":  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+				"opaque": "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			},
+			want: "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		},
+		{
+			// RFC 2617 section 3.5
+			name: "RFC 2617", user: "Mufasa", password: "Circle Of Life", cnonce: "0a4f113b",
+			params: map[string]string{
+				"realm": "testrealm@host.com", "qop": "auth,auth-int",
+				"nonce": "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+			},
+			want: "6629fae49393a05397450978507c4ef1",
+		},
+		{
+			name: "no qop", user: "Mufasa", password: "Circle Of Life", cnonce: "0a4f113b",
+			params: map[string]string{"realm": "testrealm@host.com", "nonce": "dcd98b7102dd2f0e8b11d0f600bfb0c093"},
+			want:   "670fd8c2df070c60b045671b8b24ff02",
+		},
+	}
+
+	defer func(orig func() string) { newCnonce = orig }(newCnonce)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newCnonce = func() string { return tt.cnonce }
+			req, err := http.NewRequest(http.MethodGet, "http://example.org/dir/index.html", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := digestAuthorization(req, tt.user, tt.password, tt.params, 1)
+			got := parseChallenge(strings.TrimPrefix(header, "Digest "))
+			if got["response"] != tt.want {
+				t.Errorf("response = %q, want %q (header %s)", got["response"], tt.want, header)
+			}
+			if got["uri"] != "/dir/index.html" || got["username"] != tt.user {
+				t.Errorf("unexpected uri or username in %s", header)
+			}
+			if _, ok := tt.params["qop"]; ok && (got["nc"] != "00000001" || got["cnonce"] != tt.cnonce) {
+				t.Errorf("unexpected nc or cnonce in %s", header)
+			}
+			if got["opaque"] != tt.params["opaque"] {
+				t.Errorf("opaque = %q, want %q", got["opaque"], tt.params["opaque"])
+			}
+		})
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]netrcEntry
+	}{
+		{
+			name: "one line per machine",
+			in:   "machine a.example login alice password secret\nmachine b.example login bob password hunter2\n",
+			want: map[string]netrcEntry{
+				"a.example": {login: "alice", password: "secret"},
+				"b.example": {login: "bob", password: "hunter2"},
+			},
+		},
+		{
+			name: "multi line with account and default",
+			in:   "machine a.example\n  login alice\n  account x\n  password secret\ndefault login anon password guest\n",
+			want: map[string]netrcEntry{
+				"a.example": {login: "alice", password: "secret"},
+				"":          {login: "anon", password: "guest"},
+			},
+		},
+		{
+			name: "macdef stops parsing",
+			in:   "machine a.example login alice password secret\nmacdef init\nmachine evil login x password y\n",
+			want: map[string]netrcEntry{"a.example": {login: "alice", password: "secret"}},
+		},
+		{name: "empty", in: "", want: map[string]netrcEntry{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseNetrc(strings.NewReader(tt.in)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNetrc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialsStayWithOrigin(t *testing.T) {
+	defer func(user, password, token string) {
+		authUser, authPassword, bearerToken = user, password, token
+	}(authUser, authPassword, bearerToken)
+	t.Setenv("NETRC", "/nonexistent")
+	netrcOnce = sync.Once{}
+	defer func() { netrcOnce = sync.Once{} }()
+
+	// both hosts ask for Basic credentials and record what they were sent
+	var mu sync.Mutex
+	sent := map[string][]string{}
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			sent[name] = append(sent[name], r.Header.Get("Authorization"))
+			mu.Unlock()
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}
+	asked := httptest.NewServer(handler("asked"))
+	defer asked.Close()
+	other := httptest.NewServer(handler("other"))
+	defer other.Close()
+
+	get := func(rawURL string) {
+		t.Helper()
+		ctx := withOptions(context.Background(), fileOptions{origin: originOf(asked.URL)})
+		req, err := newPlainRequest(ctx, http.MethodGet, rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := doWithRetry(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	authUser, authPassword, bearerToken = "alice", "secret", ""
+	get(asked.URL + "/file")
+	get(other.URL + "/track.png")
+	authUser, authPassword, bearerToken = "", "", "SECRET"
+	get(asked.URL + "/file")
+	get(other.URL + "/robots.txt")
+
+	mu.Lock()
+	defer mu.Unlock()
+	basic := "Basic YWxpY2U6c2VjcmV0"
+	if got := strings.Join(sent["asked"], ","); got != ","+basic+",Bearer SECRET" {
+		t.Errorf("asked host was sent %q", sent["asked"])
+	}
+	for _, header := range sent["other"] {
+		if header != "" {
+			t.Errorf("other host was sent %q", header)
+		}
+	}
+}
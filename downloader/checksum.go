@@ -0,0 +1,184 @@
+package downloader
+
+// checksum verification and manifest generation
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"wget/logger"
+)
+
+var (
+	// checksum is the expected "algo:hex" of a single download (--checksum)
+	checksum string
+	// checksumFile holds "hex  name" lines, e.g. a SHA256SUMS file
+	checksumFile map[string]string
+	// manifestPath receives a "hex  path" line for every finished download
+	manifestPath string
+	manifestMu   sync.Mutex
+)
+
+// hashAlgorithms are the algorithms accepted in "algo:hex" checksums.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// digestAlgorithms guesses the algorithm of a bare hex digest by its length.
+var digestAlgorithms = map[int]string{32: "md5", 40: "sha1", 64: "sha256", 128: "sha512"}
+
+// parseChecksum validates an "algo:hex" checksum. For a bare hex digest, as
+// found in SHA256SUMS files, the algorithm is guessed from its length.
+func parseChecksum(value string) (string, string, error) {
+	algo, digest, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		algo, digest = digestAlgorithms[len(algo)], algo
+	}
+	algo = strings.ToLower(algo)
+	newHash, known := hashAlgorithms[algo]
+	if !known {
+		return "", "", fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+	digest = strings.ToLower(digest)
+	if raw, err := hex.DecodeString(digest); err != nil || len(raw) != newHash().Size() {
+		return "", "", fmt.Errorf("invalid %s checksum %q", algo, digest)
+	}
+	return algo, digest, nil
+}
+
+// hashFile returns the hex digest of path.
+func hashFile(path, algo string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := hashAlgorithms[algo]()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadChecksumFile reads a SHA256SUMS style file ("<hex>  <name>" or
+// "<hex> *<name>") into a map keyed by file name.
+func loadChecksumFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading checksum file: %v", err)
+	}
+	defer file.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digest, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected '<checksum>  <file name>'", path, lineNum)
+		}
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		sums[name] = digest
+		sums[filepath.Base(name)] = digest
+	}
+	return sums, scanner.Err()
+}
+
+// verifyChecksum checks the data at path, to be saved as target, against
+// expected ("algo:hex") or the --checksum-file entry for the name of target
+// when expected is empty. A file that doesn't match is deleted.
+func verifyChecksum(path, target, expected string) error {
+	if expected == "" && checksumFile != nil {
+		expected = checksumFile[filepath.Base(target)]
+	}
+	if expected == "" {
+		return nil
+	}
+	algo, digest, err := parseChecksum(expected)
+	if err != nil {
+		return err
+	}
+	actual, err := hashFile(path, algo)
+	if err != nil {
+		return fmt.Errorf("hashing %s: %v", path, err)
+	}
+	if actual != digest {
+		os.Remove(path)
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s (file deleted)", algo, target, digest, actual)
+	}
+	logger.Printf("%s checksum verified for %s\n", algo, target)
+	return nil
+}
+
+// recordManifest appends the sha256 of a finished download to --manifest.
+func recordManifest(path string) error {
+	if manifestPath == "" {
+		return nil
+	}
+	digest, err := hashFile(path, "sha256")
+	if err != nil {
+		return fmt.Errorf("hashing %s: %v", path, err)
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	file, err := os.OpenFile(manifestPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("writing manifest: %v", err)
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "%s  %s\n", digest, filepath.ToSlash(path))
+	return err
+}
+
+// finishDownload verifies and records a download found already complete.
+func finishDownload(path, expected string) error {
+	if err := verifyChecksum(path, path, expected); err != nil {
+		return err
+	}
+	return recordManifest(path)
+}
+
+// configureChecksums applies the checksum flags.
+func configureChecksums(flags map[string]string) error {
+	if value := flags["checksum"]; value != "" {
+		if _, _, err := parseChecksum(value); err != nil {
+			return err
+		}
+		checksum = value
+	}
+	if path := flags["checksum-file"]; path != "" {
+		sums, err := loadChecksumFile(path)
+		if err != nil {
+			return err
+		}
+		checksumFile = sums
+	}
+	if path := flags["manifest"]; path != "" {
+		// start a fresh manifest for this run
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return fmt.Errorf("creating manifest: %v", err)
+		}
+		manifestPath = path
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package downloader
+
+import (
+	"net/http"
+)
+
+// transport is shared by every request, so proxy and TLS settings apply to
+// all fetch paths alike.
+var transport = http.DefaultTransport.(*http.Transport).Clone()
+
+// httpClient sends every request of the downloader, so transport and
+// redirect settings apply to all fetch paths alike.
+var httpClient = &http.Client{Transport: debugTransport{transport}, CheckRedirect: checkRedirect, Jar: cookies}
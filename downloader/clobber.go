@@ -0,0 +1,111 @@
+package downloader
+
+// no-clobber, backups and numbered file names on collisions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// noClobber skips downloads whose file already exists (-nc)
+	noClobber bool
+	// backups keeps this many older versions as file.1, file.2... (--backups)
+	backups int
+
+	namesMu sync.Mutex
+	// namesFree is signalled whenever a busy name is released
+	namesFree = sync.NewCond(&namesMu)
+	// claimedNames are numbered names taken for the whole run
+	claimedNames = map[string]bool{}
+	// busyNames are being written right now, together with their .part
+	busyNames = map[string]bool{}
+)
+
+// errAlreadyThere is returned by placeTarget in no-clobber mode.
+var errAlreadyThere = errors.New("file already there; not retrieving")
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// placeTarget decides where a download of target really goes and claims the
+// name, and with it the .part file, until the returned func is called. With
+// -nc an existing file stops the download. When numbered is set, and none of
+// -c, -N and --backups asks to reuse the name, a taken name becomes target.1,
+// target.2... like wget; numbered names stay claimed for the whole run and
+// skip leftover .part files.
+// Downloads that reuse the exact name wait for each other instead, so
+// concurrent -i downloads never share a file.
+func placeTarget(target string, numbered bool) (string, func(), error) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	if noClobber {
+		// a download of the same name in progress may still fail
+		for busyNames[target] {
+			namesFree.Wait()
+		}
+		if fileExists(target) {
+			return target, func() {}, errAlreadyThere
+		}
+	}
+
+	name := target
+	if !numbered || continueMode || timestamping || backups > 0 {
+		for busyNames[name] {
+			namesFree.Wait()
+		}
+	} else {
+		// a .part left by a failed run is kept for a later -c
+		for n := 1; claimedNames[name] || busyNames[name] || fileExists(name) || fileExists(partPath(name)); n++ {
+			name = fmt.Sprintf("%s.%d", target, n)
+		}
+		claimedNames[name] = true
+	}
+	busyNames[name] = true
+	return name, func() { releaseName(name) }, nil
+}
+
+// releaseName frees a name claimed by placeTarget for the next download.
+func releaseName(name string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	delete(busyNames, name)
+	namesFree.Broadcast()
+}
+
+// resumeHint tells how to pick up the partial download of target after a
+// failure. A rerun with -c uses the name it asked for, so a numbered target
+// has to be named explicitly or another file would be resumed.
+func resumeHint(asked, target string) string {
+	if target == asked {
+		return fmt.Sprintf("partial download kept in %s, use -c to resume", partPath(target))
+	}
+	return fmt.Sprintf("partial download kept in %s, to resume rerun with -c and %s as the output name (-O, or \"output\" of the -i entry)",
+		partPath(target), filepath.Base(target))
+}
+
+// rotateBackups moves target to target.1, target.1 to target.2 and so on,
+// keeping at most --backups old versions. The caller holds the claim on
+// target, so rotations of one target never overlap.
+func rotateBackups(target string) error {
+	if backups <= 0 || !fileExists(target) {
+		return nil
+	}
+	for n := backups - 1; n >= 1; n-- {
+		older := fmt.Sprintf("%s.%d", target, n)
+		if fileExists(older) {
+			if err := os.Rename(older, fmt.Sprintf("%s.%d", target, n+1)); err != nil {
+				return fmt.Errorf("rotating backups: %v", err)
+			}
+		}
+	}
+	if err := os.Rename(target, target+".1"); err != nil {
+		return fmt.Errorf("rotating backups: %v", err)
+	}
+	return nil
+}
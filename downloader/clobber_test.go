@@ -0,0 +1,157 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useNames gives a test fresh name claims and clobber settings.
+func useNames(t *testing.T) {
+	t.Helper()
+	origClaimed, origBusy := claimedNames, busyNames
+	origNoClobber, origBackups, origContinue, origTimestamping := noClobber, backups, continueMode, timestamping
+	t.Cleanup(func() {
+		claimedNames, busyNames = origClaimed, origBusy
+		noClobber, backups, continueMode, timestamping = origNoClobber, origBackups, origContinue, origTimestamping
+	})
+	claimedNames, busyNames = map[string]bool{}, map[string]bool{}
+	noClobber, backups, continueMode, timestamping = false, 0, false, false
+}
+
+func touch(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPlaceTargetNumbering(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string // files in the directory, relative to it
+		claimed  []string // names placed earlier in the run
+		numbered bool
+		setup    func()
+		want     string
+		wantErr  error
+	}{
+		{name: "free name", numbered: true, want: "a.zip"},
+		{name: "taken name", existing: []string{"a.zip"}, numbered: true, want: "a.zip.1"},
+		{name: "next free number", existing: []string{"a.zip", "a.zip.1", "a.zip.2"}, numbered: true, want: "a.zip.3"},
+		{name: "leftover part file", existing: []string{"a.zip", "a.zip.1.part"}, numbered: true, want: "a.zip.2"},
+		{name: "part file of the name itself", existing: []string{"a.zip.part"}, numbered: true, want: "a.zip.1"},
+		{name: "claimed earlier in the run", claimed: []string{"a.zip", "a.zip.1"}, numbered: true, want: "a.zip.2"},
+		{name: "asked for name", existing: []string{"a.zip"}, want: "a.zip"},
+		{name: "continue reuses the name", existing: []string{"a.zip"}, numbered: true, setup: func() { continueMode = true }, want: "a.zip"},
+		{name: "timestamping reuses the name", existing: []string{"a.zip"}, numbered: true, setup: func() { timestamping = true }, want: "a.zip"},
+		{name: "backups reuse the name", existing: []string{"a.zip"}, numbered: true, setup: func() { backups = 2 }, want: "a.zip"},
+		{name: "no clobber", existing: []string{"a.zip"}, numbered: true, setup: func() { noClobber = true }, want: "a.zip", wantErr: errAlreadyThere},
+		{name: "no clobber free name", numbered: true, setup: func() { noClobber = true }, want: "a.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNames(t)
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				touch(t, filepath.Join(dir, name))
+			}
+			target := filepath.Join(dir, "a.zip")
+			for range tt.claimed {
+				_, release, err := placeTarget(target, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				release()
+			}
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			got, release, err := placeTarget(target, tt.numbered)
+			defer release()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("placeTarget() error = %v, want %v", err, tt.wantErr)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("placeTarget() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPlaceTargetWaitsForBusyName(t *testing.T) {
+	useNames(t)
+	target := filepath.Join(t.TempDir(), "a.zip")
+	first, release, err := placeTarget(target, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	placed := make(chan string)
+	go func() {
+		second, release, err := placeTarget(target, false)
+		if err != nil {
+			t.Error(err)
+		}
+		release()
+		placed <- second
+	}()
+	select {
+	case <-placed:
+		t.Fatal("second download placed while the first was still writing")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	if second := <-placed; second != first {
+		t.Errorf("second download placed at %s, want %s", second, first)
+	}
+}
+
+func TestRotateBackups(t *testing.T) {
+	useNames(t)
+	backups = 2
+	dir := t.TempDir()
+	target := filepath.Join(dir, "a.zip")
+	// each version of the file holds its own generation number
+	for _, version := range []string{"v1", "v2", "v3", "v4"} {
+		if err := rotateBackups(target); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(version), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{"a.zip": "v4", "a.zip.1": "v3", "a.zip.2": "v2"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if fileExists(target + ".3") {
+		t.Errorf("more than --backups versions kept")
+	}
+}
+
+func TestResumeHint(t *testing.T) {
+	tests := []struct {
+		asked, target string
+		want          []string
+	}{
+		{"dir/a.zip", "dir/a.zip", []string{"dir/a.zip.part", "use -c to resume"}},
+		{"dir/a.zip", "dir/a.zip.2", []string{"dir/a.zip.2.part", "-c and a.zip.2 as the output name"}},
+	}
+	for _, tt := range tests {
+		got := resumeHint(tt.asked, tt.target)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("resumeHint(%q, %q) = %q, want it to contain %q", tt.asked, tt.target, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package downloader
+
+// persistent cookie jar with Netscape cookies.txt import/export
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wget/logger"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	// saveCookiesPath is where the jar is written on exit, set by --save-cookies
+	saveCookiesPath string
+	// keepSessionCookies also saves cookies without an expiry date
+	keepSessionCookies bool
+)
+
+// cookieRecord is a cookie as stored in cookies.txt.
+type cookieRecord struct {
+	domain     string // without the leading dot
+	subdomains bool
+	path       string
+	secure     bool
+	httpOnly   bool
+	expires    time.Time // zero for session cookies
+	name       string
+	value      string
+}
+
+func (r cookieRecord) key() string {
+	return r.domain + "\t" + r.path + "\t" + r.name
+}
+
+// recordingJar matches cookies with net/http/cookiejar and keeps its own
+// record of them, since cookiejar can't list its content for saving.
+type recordingJar struct {
+	*cookiejar.Jar
+	mu      sync.Mutex
+	records map[string]cookieRecord
+}
+
+func newRecordingJar() *recordingJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &recordingJar{Jar: jar, records: map[string]cookieRecord{}}
+}
+
+// SetCookies stores the cookies of a response, shared by every request of
+// the run so a session opened by one page applies to the following ones.
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		rec := cookieRecord{
+			domain:   u.Hostname(),
+			path:     c.Path,
+			secure:   c.Secure,
+			httpOnly: c.HttpOnly,
+			name:     c.Name,
+			value:    c.Value,
+		}
+		if c.Domain != "" {
+			rec.domain = strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+			rec.subdomains = true
+		}
+		if rec.path == "" || rec.path[0] != '/' {
+			rec.path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			delete(j.records, rec.key())
+			continue
+		case c.MaxAge > 0:
+			rec.expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			rec.expires = c.Expires
+		}
+		if !rec.expires.IsZero() && rec.expires.Before(time.Now()) {
+			delete(j.records, rec.key())
+			continue
+		}
+		if !j.accepted(rec) {
+			continue
+		}
+		j.records[rec.key()] = rec
+	}
+}
+
+// accepted reports whether cookiejar kept rec. Cookies it refuses, e.g. for
+// a foreign domain or a public suffix, are never saved, or a later
+// --load-cookies would plant them for that domain.
+func (j *recordingJar) accepted(rec cookieRecord) bool {
+	u := &url.URL{Scheme: "http", Host: rec.domain, Path: rec.path}
+	if rec.secure {
+		u.Scheme = "https"
+	}
+	if strings.Contains(rec.domain, ":") {
+		u.Host = "[" + rec.domain + "]"
+	}
+	for _, c := range j.Jar.Cookies(u) {
+		if c.Name == rec.name && c.Value == rec.value {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultCookiePath implements the RFC 6265 default-path of a request path.
+func defaultCookiePath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// cookies is the jar of every request made by the downloader.
+var cookies = newRecordingJar()
+
+// loadCookies reads a Netscape cookies.txt file into the jar.
+func loadCookies(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("loading cookies: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("%s:%d: expected 7 tab separated fields", path, lineNum)
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid expiry %q", path, lineNum, fields[4])
+		}
+
+		rec := cookieRecord{
+			domain:     strings.TrimPrefix(fields[0], "."),
+			subdomains: strings.EqualFold(fields[1], "TRUE"),
+			path:       fields[2],
+			secure:     strings.EqualFold(fields[3], "TRUE"),
+			httpOnly:   httpOnly,
+			name:       fields[5],
+			value:      fields[6],
+		}
+		if expiry > 0 {
+			rec.expires = time.Unix(expiry, 0)
+			if rec.expires.Before(time.Now()) {
+				continue
+			}
+		}
+
+		c := &http.Cookie{
+			Name:     rec.name,
+			Value:    rec.value,
+			Path:     rec.path,
+			Secure:   rec.secure,
+			HttpOnly: rec.httpOnly,
+			Expires:  rec.expires,
+		}
+		if rec.subdomains {
+			c.Domain = rec.domain
+		}
+		scheme := "http"
+		if rec.secure {
+			scheme = "https"
+		}
+		cookies.SetCookies(&url.URL{Scheme: scheme, Host: rec.domain, Path: rec.path}, []*http.Cookie{c})
+	}
+	return scanner.Err()
+}
+
+// SaveCookies writes the jar to the --save-cookies file, if one was given.
+// Session cookies are only kept with --keep-session-cookies.
+func SaveCookies() error {
+	if saveCookiesPath == "" {
+		return nil
+	}
+
+	cookies.mu.Lock()
+	records := make([]cookieRecord, 0, len(cookies.records))
+	for _, rec := range cookies.records {
+		if rec.expires.IsZero() && !keepSessionCookies {
+			continue
+		}
+		if !rec.expires.IsZero() && rec.expires.Before(time.Now()) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	cookies.mu.Unlock()
+	sort.Slice(records, func(a, b int) bool { return records[a].key() < records[b].key() })
+
+	var sb strings.Builder
+	sb.WriteString("# Netscape HTTP Cookie File\n# Generated by get-with-a-w. Edit at your own risk.\n\n")
+	for _, rec := range records {
+		domain := rec.domain
+		if rec.subdomains {
+			domain = "." + domain
+		}
+		if rec.httpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expiry int64
+		if !rec.expires.IsZero() {
+			expiry = rec.expires.Unix()
+		}
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, boolField(rec.subdomains), rec.path, boolField(rec.secure), expiry, rec.name, rec.value)
+	}
+	if err := os.WriteFile(saveCookiesPath, []byte(sb.String()), 0600); err != nil {
+		return fmt.Errorf("saving cookies: %v", err)
+	}
+	return nil
+}
+
+func boolField(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// configureCookies applies the cookie flags.
+func configureCookies(flags map[string]string) error {
+	saveCookiesPath = flags["save-cookies"]
+	keepSessionCookies = flags["keep-session-cookies"] != ""
+	if path := flags["load-cookies"]; path != "" {
+		return loadCookies(path)
+	}
+	return nil
+}
+
+// exit saves the cookie jar before terminating with code.
+func exit(code int) {
+	if err := SaveCookies(); err != nil {
+		logger.Println(err)
+	}
+	logger.Exit(code)
+}
@@ -0,0 +1,181 @@
+package downloader
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// useJar gives a test its own cookie jar and cookie settings.
+func useJar(t *testing.T) {
+	t.Helper()
+	origJar, origPath, origKeep := cookies, saveCookiesPath, keepSessionCookies
+	t.Cleanup(func() { cookies, saveCookiesPath, keepSessionCookies = origJar, origPath, origKeep })
+	cookies = newRecordingJar()
+}
+
+// jarCookies lists the "name=value" cookies the jar sends to rawURL.
+func jarCookies(t *testing.T, rawURL string) []string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cookies.Cookies(u) {
+		got = append(got, c.Name+"="+c.Value)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestCookiesRoundTrip(t *testing.T) {
+	useJar(t)
+	resp := &http.Response{Header: http.Header{"Set-Cookie": {
+		"host=1; Max-Age=3600; Path=/",
+		"shared=2; Domain=shop.example.com; Max-Age=3600; Secure; HttpOnly",
+		"session=3",
+		"gone=4; Max-Age=3600",
+		"gone=4; Max-Age=-1",
+		"planted=evil; Domain=bank.example.com; Max-Age=3600",
+		"suffix=evil; Domain=com; Max-Age=3600",
+	}}}
+	origin, _ := url.Parse("https://shop.example.com/cart/add")
+	cookies.SetCookies(origin, resp.Cookies())
+
+	dir := t.TempDir()
+	saveCookiesPath = filepath.Join(dir, "first.txt")
+	if err := SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(saveCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"shop.example.com\tFALSE\t/\tFALSE\t", "\thost\t1\n",
+		"#HttpOnly_.shop.example.com\tTRUE\t/cart\tTRUE\t", "\tshared\t2\n"} {
+		if !strings.Contains(string(first), want) {
+			t.Errorf("saved cookies lack %q:\n%s", want, first)
+		}
+	}
+	for _, unwanted := range []string{"session", "gone", "evil", "bank.example"} {
+		if strings.Contains(string(first), unwanted) {
+			t.Errorf("saved cookies contain %q:\n%s", unwanted, first)
+		}
+	}
+
+	// a fresh run loads the file and saves the very same content
+	cookies = newRecordingJar()
+	if err := loadCookies(saveCookiesPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := jarCookies(t, "https://shop.example.com/cart/view"); strings.Join(got, " ") != "host=1 shared=2" {
+		t.Errorf("cookies for shop.example.com = %v", got)
+	}
+	if got := jarCookies(t, "https://www.shop.example.com/cart/"); strings.Join(got, " ") != "shared=2" {
+		t.Errorf("cookies for www.shop.example.com = %v", got)
+	}
+	if got := jarCookies(t, "http://shop.example.com/cart/"); strings.Join(got, " ") != "host=1" {
+		t.Errorf("cookies over http = %v", got)
+	}
+	if got := jarCookies(t, "https://bank.example.com/"); len(got) != 0 {
+		t.Errorf("cookies for bank.example.com = %v", got)
+	}
+
+	saveCookiesPath = filepath.Join(dir, "second.txt")
+	if err := SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.ReadFile(saveCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("round trip changed the file:\n%s\nvs\n%s", first, second)
+	}
+}
+
+func TestSaveSessionCookies(t *testing.T) {
+	useJar(t)
+	keepSessionCookies = true
+	origin, _ := url.Parse("http://example.com/")
+	cookies.SetCookies(origin, []*http.Cookie{{Name: "session", Value: "3"}})
+	saveCookiesPath = filepath.Join(t.TempDir(), "cookies.txt")
+	if err := SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(saveCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "example.com\tFALSE\t/\tFALSE\t0\tsession\t3\n") {
+		t.Errorf("session cookie not saved:\n%s", data)
+	}
+}
+
+func TestLoadCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		url     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "comments and blank lines",
+			file: "# Netscape HTTP Cookie File\n\nexample.com\tFALSE\t/\tFALSE\t0\ta\t1\n",
+			url:  "http://example.com/", want: "a=1",
+		},
+		{
+			name: "http only and subdomains",
+			file: "#HttpOnly_.example.com\tTRUE\t/\tFALSE\t0\ta\t1\n",
+			url:  "http://www.example.com/", want: "a=1",
+		},
+		{
+			name: "expired cookies are dropped",
+			file: "example.com\tFALSE\t/\tFALSE\t1\told\t1\nexample.com\tFALSE\t/\tFALSE\t0\tnew\t2\n",
+			url:  "http://example.com/", want: "new=2",
+		},
+		{
+			name: "secure cookies stay on https",
+			file: "example.com\tFALSE\t/\tTRUE\t0\ts\t1\n",
+			url:  "http://example.com/", want: "",
+		},
+		{
+			name:    "wrong field count",
+			file:    "# header\nexample.com\tFALSE\t/\tFALSE\t0\ta\n",
+			wantErr: "cookies.txt:2: expected 7 tab separated fields",
+		},
+		{
+			name:    "bad expiry",
+			file:    "example.com\tFALSE\t/\tFALSE\tsoon\ta\t1\n",
+			wantErr: `cookies.txt:1: invalid expiry "soon"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJar(t)
+			path := filepath.Join(t.TempDir(), "cookies.txt")
+			if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := loadCookies(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+					t.Fatalf("loadCookies() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(jarCookies(t, tt.url), " "); got != tt.want {
+				t.Errorf("cookies for %s = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
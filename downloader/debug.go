@@ -0,0 +1,79 @@
+package downloader
+
+// -d dumps of every request and response header
+
+import (
+	"net/http"
+	"strings"
+
+	"wget/logger"
+)
+
+// debugTransport logs the headers of each request it sends and of each
+// response it gets with -d. Sitting below the client, it also sees the
+// cookies, authentication retries and redirect hops.
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func (t debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !logger.Enabled(logger.LevelDebug) {
+		return t.base.RoundTrip(req)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---request begin---\n")
+	sb.WriteString(req.Method + " " + req.URL.RequestURI() + " " + req.Proto + "\n")
+	sb.WriteString("Host: " + req.URL.Host + "\n")
+	redactedHeaders(req.Header).Write(&sb)
+	sb.WriteString("---request end---\n")
+	logger.Debugf("%s", sb.String())
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		logger.Debugf("---request failed: %v---\n", err)
+		return resp, err
+	}
+
+	sb.Reset()
+	sb.WriteString("---response begin---\n")
+	sb.WriteString(resp.Proto + " " + resp.Status + "\n")
+	redactedHeaders(resp.Header).Write(&sb)
+	sb.WriteString("---response end---\n")
+	logger.Debugf("%s", sb.String())
+	return resp, nil
+}
+
+// redactedHeaders returns a copy of h with credentials and cookie values
+// masked, so a debug log can be shared safely. Cookie names and attributes
+// are kept as they help to debug a session.
+func redactedHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range []string{"Authorization", "Proxy-Authorization"} {
+		if value := out.Get(name); value != "" {
+			scheme, _, _ := strings.Cut(value, " ")
+			out.Set(name, scheme+" *****")
+		}
+	}
+	for i, value := range out["Cookie"] {
+		pairs := strings.Split(value, ";")
+		for j, pair := range pairs {
+			pairs[j] = maskCookieValue(pair)
+		}
+		out["Cookie"][i] = strings.Join(pairs, ";")
+	}
+	for i, value := range out["Set-Cookie"] {
+		pair, attrs, found := strings.Cut(value, ";")
+		out["Set-Cookie"][i] = maskCookieValue(pair)
+		if found {
+			out["Set-Cookie"][i] += ";" + attrs
+		}
+	}
+	return out
+}
+
+// maskCookieValue turns "name=value" into "name=*****".
+func maskCookieValue(pair string) string {
+	name, _, _ := strings.Cut(pair, "=")
+	return name + "=*****"
+}
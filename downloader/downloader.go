@@ -2,82 +2,205 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"wget/logger"
 	"wget/utils"
 
 	"github.com/schollz/progressbar/v3"
+	"golang.org/x/time/rate"
 )
 
 var (
 	fileName      string
-	downloadPath  string
 	mirrorMode    bool
 	multiFileMode bool
 )
 
+// fileOptions are settings of a single download, e.g. from one line of an
+// -i input file.
+type fileOptions struct {
+	checksum  string        // expected "algo:hex" of the file, empty to skip
+	output    string        // file name instead of the one from the URL
+	directory string        // directory the file is saved in
+	headers   http.Header   // request headers on top of --header
+	limiter   *rate.Limiter // rate limit on top of --rate-limit
+	origin    string        // host the user asked for, see credentialsFor
+}
+
+// optionsKey is the context key of the fileOptions of a download.
+type optionsKey struct{}
+
+// withOptions returns ctx carrying opts, so that requests built from it send
+// the headers and obey the rate limit of a single download.
+func withOptions(ctx context.Context, opts fileOptions) context.Context {
+	return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// optionsFrom returns the fileOptions carried by ctx, if any.
+func optionsFrom(ctx context.Context) fileOptions {
+	opts, _ := ctx.Value(optionsKey{}).(fileOptions)
+	return opts
+}
+
 // DownloadFile downloads a file from the specified URL and saves it locally.
 // If mirrorMode is enabled, it preserves the directory structure from the URL.
 // Displays a progress bar during the download.
 func DownloadFile(fileURL string, mirrorMode bool) (*os.File, error) {
-	startTime := time.Now()
-	fmt.Printf("Start at %s\n", startTime.Format("2006-01-02 15:04:05"))
+	return downloadFile(fileURL, mirrorMode, fileOptions{})
+}
 
-	// Perform HTTP GET request
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return nil, fmt.Errorf("sending request failed: %v", err)
+// MirrorFile downloads fileURL as part of a mirror that started at the host
+// origin. Pages and assets on other hosts don't get the credentials meant
+// for origin.
+func MirrorFile(fileURL, origin string) (*os.File, error) {
+	return downloadFile(fileURL, true, fileOptions{origin: origin})
+}
+
+// originOf returns the host of fileURL, the origin of a download the user
+// asked for directly.
+func originOf(fileURL string) string {
+	if u, err := url.Parse(fileURL); err == nil {
+		return u.Host
 	}
-	defer resp.Body.Close()
+	return ""
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, nil // No file downloaded for non-OK status
+func downloadFile(fileURL string, mirrorMode bool, opts fileOptions) (*os.File, error) {
+	if opts.origin == "" {
+		opts.origin = originOf(fileURL)
 	}
-	fmt.Println("Sending request, awaiting response... status 200 OK")
+	ev := startEvent(fileURL)
+	file, err := fetchFile(fileURL, mirrorMode, opts, ev)
+	ev.finish(err)
+	return file, err
+}
 
-	// Log content size
-	size := resp.ContentLength
-	fmt.Printf("Content size: %d [~%.2fMB]\n", size, float64(size)/(1024*1024))
+// fetchFile does the work of downloadFile, filling in ev as it goes.
+func fetchFile(fileURL string, mirrorMode bool, opts fileOptions, ev *downloadEvent) (*os.File, error) {
+	startTime := time.Now()
+	logger.Printf("Start at %s\n", startTime.Format("2006-01-02 15:04:05"))
+	ctx := withOptions(context.Background(), opts)
 
 	// Generate target download path based on mirror mode
-	if mirrorMode {
-		parsedURL, err := url.Parse(fileURL)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing URL: %v", err)
+	fileName, err := LocalPath(fileURL, mirrorMode)
+	if err != nil {
+		return nil, err
+	}
+	// early is the download response when it decided the file name
+	var early *http.Response
+	if opts.output != "" {
+		fileName = opts.output
+	} else if !mirrorMode {
+		// mirrors keep URL based names so converted links stay valid
+		if early, fileName, err = namedResponse(ctx, fileURL, fileName); err != nil {
+			return nil, fmt.Errorf("sending request failed: %v", err)
 		}
-
-		if parsedURL.Path == "" {
-			downloadPath = parsedURL.Host + string(os.PathSeparator)
-		} else {
-			downloadPath, _ = path.Split(parsedURL.Host + parsedURL.Path)
+		if early != nil {
+			defer early.Body.Close()
 		}
-
+	}
+	if opts.directory != "" {
+		fileName = filepath.Join(opts.directory, fileName)
+	}
+	// mirrors update their files in place, other downloads get a free name
+	// unless the name was asked for, as with -O
+	askedName := fileName
+	fileName, release, err := placeTarget(fileName, !mirrorMode && opts.output == "")
+	defer release()
+	if errors.Is(err, errAlreadyThere) {
+		logger.Printf("File '%s' already there; not retrieving.\n", fileName)
+		ev.skip(fileName)
+		return os.OpenFile(fileName, os.O_RDWR, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mirrorMode || opts.directory != "" {
 		// Ensure download directory exists
-		if err := os.MkdirAll(downloadPath, os.ModePerm); err != nil {
-			return nil, fmt.Errorf("failed to create directory: %s, error: %v", downloadPath, err)
+		if dir := filepath.Dir(fileName); dir != "." {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return nil, fmt.Errorf("failed to create directory: %s, error: %v", dir, err)
+			}
 		}
 	}
 
-	// Generate a file name for the downloaded content
-	fileName, err = utils.MakeAName(fileURL)
+	// Large files can be fetched over several connections at once
+	file, segmented, err := downloadSegmented(ctx, fileURL, fileName, func(format string, a ...any) {
+		logger.Printf(format+"\n", a...)
+	}, !hideProgress())
+	if segmented {
+		if err != nil {
+			return nil, fmt.Errorf("segmented download failed: %v", err)
+		}
+		if file, err = commitTarget(file, fileName, opts.checksum); err != nil {
+			return nil, err
+		}
+		if info, err := file.Stat(); err == nil {
+			ev.Bytes = info.Size()
+		}
+		ev.Status, ev.Destination = http.StatusPartialContent, fileName
+		logger.Printf("\nDownloaded [%s]\nFinished at %s\n", fileURL, time.Now().Format("2006-01-02 15:04:05"))
+		return file, nil
+	}
+
+	// Perform HTTP GET request, resuming a partial file when asked to
+	resp, offset := early, int64(0)
+	if early == nil {
+		resp, offset, err = getResumable(ctx, fileURL, fileName)
+	} else if early.StatusCode == http.StatusOK {
+		saveResumeState(fileName, early)
+	}
+	if errors.Is(err, errNotModified) {
+		logger.Printf("Server file no newer than local file '%s'; not retrieving.\n", fileName)
+		ev.skip(fileName)
+		return os.OpenFile(fileName, os.O_RDWR, 0644)
+	}
+	if errors.Is(err, errAlreadyComplete) {
+		logger.Println(err)
+		ev.skip(fileName)
+		if err := promotePartial(fileName); err != nil {
+			return nil, err
+		}
+		if err := finishDownload(fileName, opts.checksum); err != nil {
+			return nil, err
+		}
+		return os.OpenFile(fileName, os.O_RDWR, 0644)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error generating file name: %v", err)
+		return nil, fmt.Errorf("sending request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	ev.Status = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		if permanentStatus(resp.StatusCode) {
+			discardPartial(fileName)
+		}
+		return nil, fmt.Errorf("server returned %s", resp.Status)
 	}
+	logger.Printf("Sending request, awaiting response... status %s\n", resp.Status)
 
-	if mirrorMode {
-		fileName = filepath.Join(downloadPath, fileName)
+	// Log content size
+	size := resp.ContentLength
+	if offset > 0 && size >= 0 {
+		logger.Printf("Resuming at byte %d\n", offset)
+		size += offset
 	}
+	logger.Printf("Content size: %d [~%.2fMB]\n", size, float64(size)/(1024*1024))
 
-	// Create destination file
-	file, err := os.Create(fileName)
+	// Create destination file, keeping already downloaded bytes when resuming
+	file, err = openTarget(fileName, offset)
 	if err != nil {
 		return nil, fmt.Errorf("error creating file: %v", err)
 	}
@@ -85,26 +208,69 @@ func DownloadFile(fileURL string, mirrorMode bool) (*os.File, error) {
 	// Print download destination
 	absFilePath, err := filepath.Abs(filepath.Dir(file.Name()))
 	if err != nil {
-		log.Fatalf("Error getting absolute path: %v", err)
+		file.Close()
+		return nil, fmt.Errorf("error getting absolute path: %v", err)
 	}
-	fmt.Printf("Saving file to: %s\n", filepath.Join(absFilePath))
-	fmt.Println("File name:", fileName)
+	logger.Printf("Saving file to: %s\n", filepath.Join(absFilePath))
+	logger.Println("File name:", fileName)
 
 	// Setup progress bar and multi-writer
-	bar := progressbar.DefaultBytes(size, "Downloading")
+	bar := newBar(size)
+	bar.Set64(offset)
 	writer := io.MultiWriter(file, bar)
 
 	// Perform the file download
-	_, err = io.Copy(writer, resp.Body)
+	ev.Bytes, err = copyBody(ctx, fileURL, writer, resp, offset)
 	if err != nil {
-		return nil, fmt.Errorf("error writing to file: %v", err)
+		file.Close()
+		return nil, fmt.Errorf("error writing to file: %v (%s)", err, resumeHint(askedName, fileName))
 	}
+	if file, err = commitTarget(file, fileName, opts.checksum); err != nil {
+		return nil, err
+	}
+	ev.Destination = fileName
 
 	finishTime := time.Now()
-	fmt.Printf("\nDownloaded [%s]\nFinished at %s\n", fileURL, finishTime.Format("2006-01-02 15:04:05"))
+	logger.Printf("\nDownloaded [%s]\nFinished at %s\n", fileURL, finishTime.Format("2006-01-02 15:04:05"))
 	return file, nil
 }
 
+// newBar returns the progress bar of a download, kept silent when the log
+// goes to a file or -nv / -q are set.
+func newBar(size int64) *progressbar.ProgressBar {
+	if hideProgress() {
+		return progressbar.DefaultBytesSilent(size, "Downloading")
+	}
+	return progressbar.DefaultBytes(size, "Downloading")
+}
+
+// LocalPath returns the path fileURL is saved to. In mirror mode the host and
+// directories of the URL are kept, and a last path segment without an
+// extension is treated as a directory holding index.html so that pages like
+// /about and / don't overwrite each other.
+func LocalPath(fileURL string, mirrorMode bool) (string, error) {
+	// Generate a file name for the downloaded content
+	name, err := utils.MakeAName(fileURL)
+	if err != nil {
+		return "", fmt.Errorf("error generating file name: %v", err)
+	}
+	if !mirrorMode {
+		return name, nil
+	}
+
+	parsedURL, err := url.Parse(fileURL)
+	if err != nil {
+		return "", fmt.Errorf("error parsing URL: %v", err)
+	}
+	dir := parsedURL.Path
+	if !strings.HasSuffix(dir, "/") {
+		if base := path.Base(dir); strings.Contains(base, ".") {
+			dir = path.Dir(dir)
+		}
+	}
+	return filepath.Join(parsedURL.Host, filepath.FromSlash(dir), name), nil
+}
+
 // SetFileName allows setting the output file name manually.
 func SetFileName(name string) {
 	fileName = name
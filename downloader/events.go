@@ -0,0 +1,61 @@
+package downloader
+
+// structured download events for --log-format json
+
+import (
+	"io"
+	"time"
+
+	"wget/logger"
+)
+
+// downloadEvent collects what the JSON log reports about one download while
+// it runs.
+type downloadEvent struct {
+	logger.Event
+	start time.Time
+}
+
+func startEvent(fileURL string) *downloadEvent {
+	return &downloadEvent{Event: logger.Event{URL: fileURL}, start: time.Now()}
+}
+
+// skip marks a download that found nothing to fetch, e.g. with -nc or -N.
+func (e *downloadEvent) skip(destination string) {
+	e.Event.Event = "skipped"
+	e.Destination = destination
+}
+
+// finish emits the event with the outcome of the download.
+func (e *downloadEvent) finish(err error) {
+	switch {
+	case err != nil:
+		e.Event.Event = "error"
+		e.Error = err.Error()
+	case e.Event.Event == "":
+		e.Event.Event = "done"
+	}
+	e.Duration = time.Since(e.start).Seconds()
+	logger.Emit(e.Event)
+
+	// -nv replaces the detailed messages with one line per file
+	if err == nil && !logger.Enabled(logger.LevelNormal) {
+		stamp := time.Now().Format("2006-01-02 15:04:05")
+		if e.Event.Event == "skipped" {
+			logger.Infof("%s URL:%s -> \"%s\" [not retrieved]\n", stamp, e.URL, e.Destination)
+		} else {
+			logger.Infof("%s URL:%s [%d] -> \"%s\"\n", stamp, e.URL, e.Bytes, e.Destination)
+		}
+	}
+}
+
+// hideProgress reports whether progress bars are left out: when the log
+// goes to a file or -nv / -q are set.
+func hideProgress() bool {
+	return logger.ToFile() || !logger.Enabled(logger.LevelNormal)
+}
+
+// errorWriter is where warnings and errors go, see logger.Errorf.
+func errorWriter() io.Writer {
+	return logger.LevelWriter(logger.LevelTerse)
+}
@@ -0,0 +1,335 @@
+package downloader
+
+// -i input files - plain, JSONL and CSV lists with per-entry options
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wget/utils"
+)
+
+// inputFormat is the format of the -i file, set by --input-format
+var inputFormat = "auto"
+
+// inputFormats are the values --input-format accepts.
+var inputFormats = map[string]bool{"auto": true, "plain": true, "jsonl": true, "csv": true}
+
+// entrySpec is one entry of an -i file before validation. JSONL lines decode
+// into it directly, CSV rows and plain lines are mapped onto it.
+type entrySpec struct {
+	URL       string            `json:"url"`
+	Output    string            `json:"output"`
+	Directory string            `json:"directory"`
+	Headers   map[string]string `json:"headers"`
+	Checksum  string            `json:"checksum"`
+	RateLimit string            `json:"rate_limit"`
+
+	line    int      // line of the entry in the input file
+	rawHead []string // "Name: value" headers of a CSV row
+}
+
+// csvColumns are the columns a CSV input file may have. "header" can repeat.
+var csvColumns = map[string]bool{
+	"url": true, "output": true, "directory": true, "header": true, "checksum": true, "rate_limit": true,
+}
+
+// parseInputList reads and validates every entry of an -i file before
+// anything is downloaded. Errors of all entries are reported together, each
+// prefixed with name and its line number. Blank lines and lines starting
+// with '#' are skipped in every format.
+func parseInputList(r io.Reader, name, format string) ([]listEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if format == "auto" || format == "" {
+		format = detectFormat(name, data)
+	}
+
+	var specs []entrySpec
+	var errs []error
+	switch format {
+	case "plain":
+		specs, errs = parsePlainList(data)
+	case "jsonl":
+		specs, errs = parseJSONList(data)
+	case "csv":
+		specs, errs = parseCSVList(data)
+	default:
+		return nil, fmt.Errorf("invalid --input-format %q, expected auto, plain, jsonl or csv", format)
+	}
+
+	var entries []listEntry
+	for _, spec := range specs {
+		entry, err := spec.entry()
+		if err != nil {
+			errs = append(errs, lineError{spec.line, err})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errorLine(errs[i]) < errorLine(errs[j]) })
+		for i, err := range errs {
+			if errorLine(err) > 0 {
+				errs[i] = fmt.Errorf("%s:%v", name, err)
+			} else {
+				errs[i] = fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+	return entries, nil
+}
+
+// lineError is a validation error of one line of an -i file.
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e lineError) Error() string {
+	return fmt.Sprintf("%d: %v", e.line, e.err)
+}
+
+// errorLine returns the line of err, or 0 for errors of the whole file.
+func errorLine(err error) int {
+	if e, ok := err.(lineError); ok {
+		return e.line
+	}
+	return 0
+}
+
+// detectFormat guesses the format of an -i file from its extension, or else
+// from its first entry: '{' starts JSONL and a "url" first column a CSV
+// header.
+func detectFormat(name string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".csv":
+		return "csv"
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "{"):
+			return "jsonl"
+		case strings.EqualFold(strings.Trim(strings.SplitN(line, ",", 2)[0], ` "`), "url") && strings.Contains(line, ","):
+			return "csv"
+		}
+		return "plain"
+	}
+	return "plain"
+}
+
+// parsePlainList reads lines of a URL optionally followed by its checksum.
+func parsePlainList(data []byte) ([]entrySpec, []error) {
+	var specs []entrySpec
+	var errs []error
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		spec := entrySpec{URL: fields[0], line: lineNum}
+		switch len(fields) {
+		case 1:
+		case 2:
+			spec.Checksum = fields[1]
+		default:
+			errs = append(errs, lineError{lineNum, errors.New("expected '<url> [checksum]'")})
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, lineError{lineNum, err})
+	}
+	return specs, errs
+}
+
+// parseJSONList reads one JSON object per line. Unknown keys are errors so a
+// typo doesn't silently drop an option.
+func parseJSONList(data []byte) ([]entrySpec, []error) {
+	var specs []entrySpec
+	var errs []error
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var spec entrySpec
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&spec); err != nil {
+			errs = append(errs, lineError{lineNum, fmt.Errorf("invalid JSON: %v", err)})
+			continue
+		}
+		if decoder.More() {
+			errs = append(errs, lineError{lineNum, errors.New("invalid JSON: more than one object on the line")})
+			continue
+		}
+		spec.line = lineNum
+		specs = append(specs, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, lineError{lineNum, err})
+	}
+	return specs, errs
+}
+
+// parseCSVList reads a CSV file whose first row names the columns, see
+// csvColumns. Only the url column is required.
+func parseCSVList(data []byte) ([]entrySpec, []error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{csvError(err)}
+	}
+	headerLine, _ := reader.FieldPos(0)
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !csvColumns[column] {
+			return nil, []error{lineError{headerLine, fmt.Errorf("unknown column %q", column)}}
+		}
+		if seen[column] && column != "header" {
+			return nil, []error{lineError{headerLine, fmt.Errorf("duplicate column %q", column)}}
+		}
+		seen[column] = true
+		header[i] = column
+	}
+	if !seen["url"] {
+		return nil, []error{lineError{headerLine, errors.New("missing url column")}}
+	}
+
+	var specs []entrySpec
+	var errs []error
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, csvError(err))
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				continue // the reader carries on with the next row
+			}
+			break
+		}
+		spec := entrySpec{}
+		spec.line, _ = reader.FieldPos(0)
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "url":
+				spec.URL = value
+			case "output":
+				spec.Output = value
+			case "directory":
+				spec.Directory = value
+			case "header":
+				if value != "" {
+					spec.rawHead = append(spec.rawHead, value)
+				}
+			case "checksum":
+				spec.Checksum = value
+			case "rate_limit":
+				spec.RateLimit = value
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, errs
+}
+
+// csvError gives a CSV reader error the line it occurred on.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return lineError{parseErr.Line, parseErr.Err}
+	}
+	return err
+}
+
+// entry validates spec and turns it into a download.
+func (spec entrySpec) entry() (listEntry, error) {
+	if spec.URL == "" {
+		return listEntry{}, errors.New("missing url")
+	}
+	link := utils.EnsureScheme(spec.URL)
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return listEntry{}, fmt.Errorf("invalid url %q: %v", spec.URL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return listEntry{}, fmt.Errorf("invalid url %q, expected an http or https URL", spec.URL)
+	}
+	entry := listEntry{url: link}
+
+	if spec.Output != "" {
+		// names come from the list, so they may not leave the directory
+		if spec.Output != sanitizeName(spec.Output) {
+			return listEntry{}, fmt.Errorf("invalid output %q, expected a plain file name", spec.Output)
+		}
+		entry.opts.output = spec.Output
+	}
+	entry.opts.directory = spec.Directory
+
+	lines := spec.rawHead
+	for name, value := range spec.Headers {
+		if strings.ContainsAny(name+value, "\r\n") {
+			return listEntry{}, fmt.Errorf("invalid header %q, line breaks are not allowed", name)
+		}
+		lines = append(lines, name+": "+value)
+	}
+	if len(lines) > 0 {
+		headers, err := parseHeaders(strings.Join(lines, "\n"))
+		if err != nil {
+			return listEntry{}, err
+		}
+		entry.opts.headers = headers
+	}
+
+	if spec.Checksum != "" {
+		if _, _, err := parseChecksum(spec.Checksum); err != nil {
+			return listEntry{}, err
+		}
+		entry.opts.checksum = spec.Checksum
+	}
+	if spec.RateLimit != "" {
+		if entry.opts.limiter, err = newLimiter(spec.RateLimit); err != nil {
+			return listEntry{}, err
+		}
+	}
+	return entry, nil
+}
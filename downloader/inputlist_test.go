@@ -0,0 +1,187 @@
+package downloader
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseInputList(t *testing.T) {
+	sha := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name   string
+		file   string
+		format string
+		input  string
+		want   []string // "url output directory checksum header=..." per entry
+	}{
+		{
+			name: "plain with comments", file: "urls.txt",
+			input: "# list\n\nhttp://a.example/x.zip\n  example.com/y.zip " + sha + "\n",
+			want:  []string{"http://a.example/x.zip", "https://example.com/y.zip checksum=" + sha},
+		},
+		{
+			name: "jsonl by extension", file: "urls.jsonl",
+			input: "# list\n" +
+				`{"url": "http://a.example/x", "output": "x.bin", "directory": "out", "headers": {"x-team": "ops"}, "rate_limit": "200k"}` + "\n\n" +
+				`{"url": "http://a.example/y", "checksum": "` + sha + `"}` + "\n",
+			want: []string{
+				"http://a.example/x output=x.bin directory=out header=X-Team:ops limited",
+				"http://a.example/y checksum=" + sha,
+			},
+		},
+		{
+			name: "jsonl sniffed", file: "-",
+			input: "\n# comment\n{\"url\": \"http://a.example/x\"}\n",
+			want:  []string{"http://a.example/x"},
+		},
+		{
+			name: "csv sniffed with repeated header column", file: "list",
+			input: "url,output,header,header\n# skipped\nhttp://a.example/x,x.bin,Accept: text/plain,X-Team: ops\n\nhttp://a.example/y,,,\n",
+			want: []string{
+				"http://a.example/x output=x.bin header=Accept:text/plain header=X-Team:ops",
+				"http://a.example/y",
+			},
+		},
+		{
+			name: "format flag wins over the extension", file: "urls.csv", format: "plain",
+			input: "http://a.example/x\n",
+			want:  []string{"http://a.example/x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := tt.format
+			if format == "" {
+				format = "auto"
+			}
+			entries, err := parseInputList(strings.NewReader(tt.input), tt.file, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, describeEntry(e))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// describeEntry renders e for comparison in tests.
+func describeEntry(e listEntry) string {
+	parts := []string{e.url}
+	if e.opts.output != "" {
+		parts = append(parts, "output="+e.opts.output)
+	}
+	if e.opts.directory != "" {
+		parts = append(parts, "directory="+e.opts.directory)
+	}
+	if e.opts.checksum != "" {
+		parts = append(parts, "checksum="+e.opts.checksum)
+	}
+	names := make([]string, 0, len(e.opts.headers))
+	for name := range e.opts.headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range e.opts.headers[name] {
+			parts = append(parts, "header="+name+":"+value)
+		}
+	}
+	if e.opts.limiter != nil {
+		parts = append(parts, "limited")
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseInputListErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		input string
+		want  []string
+	}{
+		{
+			name: "plain", file: "urls.txt",
+			input: "# ok\nhttp://a.example/x too many fields\n\nftp://a.example/y\nhttp://a.example/z md5:zz\n",
+			want: []string{
+				"urls.txt:2: expected '<url> [checksum]'",
+				`urls.txt:4: invalid url "ftp://a.example/y", expected an http or https URL`,
+				`urls.txt:5: invalid md5 checksum "zz"`,
+			},
+		},
+		{
+			name: "jsonl in line order", file: "urls.jsonl",
+			input: `{"url": "http://a.example/x", "output": "../evil"}` + "\n" +
+				"# comment\n" +
+				`{"url": "http://a.example/x", "typo": 1}` + "\n" +
+				"not json\n" +
+				`{"output": "x"}` + "\n" +
+				`{"url": "http://a.example/x", "rate_limit": "fast"}` + "\n" +
+				`{"url": "http://a.example/x", "headers": {"Bad Name": "v"}}` + "\n" +
+				`{"url": "http://a.example/x", "headers": {"X": "a\r\nInjected: 1"}}` + "\n",
+			want: []string{
+				`urls.jsonl:1: invalid output "../evil", expected a plain file name`,
+				`urls.jsonl:3: invalid JSON: json: unknown field "typo"`,
+				"urls.jsonl:4: invalid JSON:",
+				"urls.jsonl:5: missing url",
+				"urls.jsonl:6: error adjusting rate limit:",
+				`urls.jsonl:7: invalid header "Bad Name: v", expected 'Name: value'`,
+				`urls.jsonl:8: invalid header "X", line breaks are not allowed`,
+			},
+		},
+		{
+			name: "csv rows", file: "urls.csv",
+			input: "url,checksum\n\nhttp://a.example/x,sha1:00\nhttp://a.example/y,,extra\n",
+			want: []string{
+				`urls.csv:3: invalid sha1 checksum "00"`,
+				"urls.csv:4: wrong number of fields",
+			},
+		},
+		{
+			name: "csv unknown column", file: "urls.csv",
+			input: "# list\nurl,outptu\nhttp://a.example/x,x\n",
+			want:  []string{`urls.csv:2: unknown column "outptu"`},
+		},
+		{
+			name: "csv without url column", file: "urls.csv",
+			input: "output\nx\n",
+			want:  []string{"urls.csv:1: missing url column"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseInputList(strings.NewReader(tt.input), tt.file, "auto")
+			if err == nil {
+				t.Fatalf("no error, got %d entries", len(entries))
+			}
+			if entries != nil {
+				t.Errorf("entries returned along with errors")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d:\n%v", len(lines), len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("error %d = %q, want prefix %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInputListFormat(t *testing.T) {
+	if _, err := parseInputList(strings.NewReader("http://a.example/x\n"), "urls.txt", "xml"); err == nil ||
+		!strings.Contains(err.Error(), `invalid --input-format "xml"`) {
+		t.Errorf("unknown format error = %v", err)
+	}
+	entries, err := parseInputList(strings.NewReader("# nothing\n\n"), "urls.txt", "auto")
+	if err != nil || len(entries) != 0 {
+		t.Errorf("empty list = %v, %v", entries, err)
+	}
+}
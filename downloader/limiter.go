@@ -38,9 +38,51 @@ func adjustRateLimit(rateLimit string) (int, error) {
 	return rate * multiplier * 9 / 10, nil // apply 90% overhead factor
 }
 
-// Setup rate limiter if provided
+// limiter is the one bandwidth budget shared by every transfer in flight,
+// whatever the mode (single file, segments, -i, --mirror or the web server).
 var limiter *rate.Limiter
 
+// limiterBurst is the bucket size of the shared limiter.
+const limiterBurst = 64 * 1024 // 64 KB burst
+
+// limiterChunk caps how many bytes a single read may reserve, so concurrent
+// transfers take turns in small slices and share the budget fairly.
+const limiterChunk = limiterBurst / 4
+
+// setRateLimit installs the shared limiter from a --rate-limit value.
+func setRateLimit(value string) error {
+	shared, err := newLimiter(value)
+	if err != nil {
+		return err
+	}
+	limiter = shared
+	return nil
+}
+
+// limitReader wraps body with the shared limiter when one is configured,
+// and with the own limit of an -i entry carried by ctx.
+func limitReader(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if own := optionsFrom(ctx).limiter; own != nil {
+		body = &rateLimitedReader{ReadCloser: body, limiter: own}
+	}
+	if limiter == nil {
+		return body
+	}
+	return &rateLimitedReader{ReadCloser: body, limiter: limiter}
+}
+
+// newLimiter returns a limiter for a rate such as "500k" or "2M".
+func newLimiter(value string) (*rate.Limiter, error) {
+	rateLimit, err := adjustRateLimit(value)
+	if err != nil {
+		return nil, fmt.Errorf("error adjusting rate limit: %v", err)
+	}
+	if rateLimit <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %q", value)
+	}
+	return rate.NewLimiter(rate.Limit(rateLimit), limiterBurst), nil
+}
+
 // rateLimitedReader wraps an io.ReadCloser and applies rate limiting
 type rateLimitedReader struct {
 	io.ReadCloser
@@ -48,12 +90,15 @@ type rateLimitedReader struct {
 }
 
 func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > limiterChunk {
+		p = p[:limiterChunk]
+	}
 	n, err := r.ReadCloser.Read(p)
-	if err != nil {
+	if n == 0 {
 		return n, err
 	}
 	if err := r.limiter.WaitN(context.Background(), n); err != nil {
 		return n, err
 	}
-	return n, nil
+	return n, err
 }
@@ -0,0 +1,203 @@
+package downloader
+
+// file names from Content-Disposition and Content-Type
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"wget/utils"
+)
+
+var (
+	// contentDisposition names files after the server's Content-Disposition
+	contentDisposition bool
+	// adjustExtension adds the extension matching the Content-Type (-E)
+	adjustExtension bool
+)
+
+// typeExtensions is the extension -E gives each content type. mime's own
+// table lists several per type in no useful order.
+var typeExtensions = map[string]string{
+	"text/html":              ".html",
+	"application/xhtml+xml":  ".html",
+	"text/css":               ".css",
+	"text/plain":             ".txt",
+	"text/csv":               ".csv",
+	"text/xml":               ".xml",
+	"application/xml":        ".xml",
+	"application/json":       ".json",
+	"text/javascript":        ".js",
+	"application/javascript": ".js",
+	"application/pdf":        ".pdf",
+	"application/zip":        ".zip",
+	"application/gzip":       ".gz",
+	"application/x-gzip":     ".gz",
+	"application/x-tar":      ".tar",
+	"image/png":              ".png",
+	"image/jpeg":             ".jpg",
+	"image/gif":              ".gif",
+	"image/svg+xml":          ".svg",
+	"image/webp":             ".webp",
+}
+
+// namedByResponse reports whether --content-disposition, --adjust-extension
+// or --trust-server-names let the response decide the file name.
+func namedByResponse() bool {
+	return (contentDisposition || adjustExtension || trustServerNames) && method == http.MethodGet && body == nil
+}
+
+// namedResponse starts the download of fileURL when its file name comes from
+// the response, like wget does, and returns the response with the name.
+// -c, -N and --segments need the name for their own request, so for them it
+// is asked for with a HEAD request instead and resp is nil, as it is when
+// the response doesn't decide the name. name, derived from the URL, is kept
+// when the server gives nothing better.
+func namedResponse(ctx context.Context, fileURL, name string) (*http.Response, string, error) {
+	if !namedByResponse() {
+		return nil, name, nil
+	}
+	if continueMode || timestamping || segments > 1 {
+		return nil, remoteName(ctx, fileURL, name), nil
+	}
+	req, err := newRequest(ctx, fileURL)
+	if err != nil {
+		return nil, name, err
+	}
+	resp, err := doWithRetry(req)
+	if err != nil {
+		return nil, name, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		name = responseName(resp, fileURL, name)
+	}
+	return resp, name, nil
+}
+
+// remoteName asks the server with a HEAD request for the file name of
+// fileURL, see responseName.
+func remoteName(ctx context.Context, fileURL, name string) string {
+	req, err := newPlainRequest(ctx, http.MethodHead, fileURL)
+	if err != nil {
+		return name
+	}
+	resp, err := doWithRetry(req)
+	if err != nil {
+		return name
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return name
+	}
+	return responseName(resp, fileURL, name)
+}
+
+// responseName returns the file name resp gives the download of fileURL,
+// or name when it gives nothing better.
+func responseName(resp *http.Response, fileURL, name string) string {
+	nameURL := fileURL
+	if trustServerNames {
+		nameURL = resp.Request.URL.String()
+		if final, err := utils.MakeAName(nameURL); err == nil {
+			name = final
+		}
+	}
+	if contentDisposition {
+		if server := dispositionName(resp.Header.Get("Content-Disposition")); server != "" {
+			return withTypeExtension(server, resp.Header.Get("Content-Type"))
+		}
+	}
+	return withTypeExtension(urlBaseName(nameURL, name), resp.Header.Get("Content-Type"))
+}
+
+// dispositionName extracts a safe file name from a Content-Disposition
+// header. An RFC 5987 filename* parameter wins over a plain filename.
+func dispositionName(header string) string {
+	if header == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err == nil {
+		return sanitizeName(params["filename"])
+	}
+	// servers often send unquoted names with spaces, which mime rejects
+	_, value, ok := strings.Cut(header, "filename=")
+	if !ok {
+		return ""
+	}
+	value, _, _ = strings.Cut(value, ";")
+	return sanitizeName(strings.Trim(strings.TrimSpace(value), `"`))
+}
+
+// sanitizeName reduces a server supplied name to a plain file name: any
+// directories are dropped so "../../etc/passwd" can't escape the download
+// directory, as are control characters and leading dots. A name of only
+// slashes or dots comes out empty.
+func sanitizeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		// path.Base leaves a lone "/" for names made of slashes
+		if r < 0x20 || r == 0x7f || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(strings.TrimLeft(name, "."))
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+// urlBaseName undoes the index.html fallback of utils.MakeAName for URLs
+// like /download?id=42, whose last path segment is a better base once the
+// extension is known.
+func urlBaseName(fileURL, name string) string {
+	if !adjustExtension || name != "index.html" {
+		return name
+	}
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return name
+	}
+	if base := sanitizeName(path.Base(parsed.Path)); base != "" && base != "/" {
+		return base
+	}
+	return name
+}
+
+// withTypeExtension appends the extension of contentType to name with -E,
+// unless name already carries one of that type.
+func withTypeExtension(name, contentType string) string {
+	if !adjustExtension {
+		return name
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return name
+	}
+	ext, ok := typeExtensions[mediaType]
+	if !ok {
+		return name
+	}
+	current := strings.ToLower(filepath.Ext(name))
+	if current == ext || (ext == ".html" && current == ".htm") {
+		return name
+	}
+	if known, _, err := mime.ParseMediaType(mime.TypeByExtension(current)); err == nil && known == mediaType {
+		return name
+	}
+	if name == "index.html" {
+		name = "index"
+	}
+	return name + ext
+}
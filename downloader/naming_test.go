@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeName(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../x", "x"},
+		{"../../etc/passwd", "passwd"},
+		{`..\x`, "x"},
+		{`..\..\boot.ini`, "boot.ini"},
+		{"/etc/passwd", "passwd"},
+		{"dir/", "dir"},
+		{"..", ""},
+		{".", ""},
+		{"...", ""},
+		{"/", ""},
+		{"//", ""},
+		{`\`, ""},
+		{"", ""},
+		{".hidden", "hidden"},
+		{" spaced name.txt ", "spaced name.txt"},
+		{"a\x00b\nc\r.txt", "abc.txt"},
+		{"a\x1b[31mred\x7f.txt", "a[31mred.txt"},
+		{long + ".txt", long[:251] + ".txt"},
+		{long + "." + strings.Repeat("b", 20), long[:255]},
+	}
+	for _, tt := range tests {
+		if got := sanitizeName(tt.in); got != tt.want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeNameMultiByte(t *testing.T) {
+	// truncation at 255 bytes must not split a character
+	got := sanitizeName(strings.Repeat("é", 200) + ".txt")
+	if len(got) > 255 || !utf8.ValidString(got) || !strings.HasSuffix(got, "é.txt") {
+		t.Errorf("sanitizeName() = %q (%d bytes)", got, len(got))
+	}
+}
+
+func TestDispositionName(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{`attachment; filename="report.pdf"`, "report.pdf"},
+		{`attachment; filename=report.pdf`, "report.pdf"},
+		{`attachment; filename=my report.pdf`, "my report.pdf"},
+		{`attachment; filename=my report.pdf; size=10`, "my report.pdf"},
+		{`attachment; filename="plain.txt"; filename*=UTF-8''%C3%A9t%C3%A9.txt`, "été.txt"},
+		{`attachment; filename*=UTF-8''%C3%A9t%C3%A9.txt; filename="plain.txt"`, "été.txt"},
+		{`attachment; filename="../../etc/passwd"`, "passwd"},
+		{`attachment; filename*=UTF-8''..%2F..%2F.bashrc`, "bashrc"},
+		{`attachment; filename="..\\..\\boot.ini"`, "boot.ini"},
+		{`attachment; filename=..\..\boot.ini`, "boot.ini"},
+		{`inline; filename=".."`, ""},
+		{`attachment; filename="/"`, ""},
+		{`attachment; filename="a` + "\x01" + `b.txt"`, "ab.txt"},
+		{`attachment`, ""},
+		{`inline`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := dispositionName(tt.header); got != tt.want {
+			t.Errorf("dispositionName(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Configure applies the download related flags to the package settings so
+// every mode (single file, -i, --mirror and the web server) behaves the same.
+func Configure(flags map[string]string) error {
+	continueMode = flags["continue"] != ""
+	noClobber = flags["no-clobber"] != ""
+	timestamping = flags["timestamping"] != ""
+	contentDisposition = flags["content-disposition"] != ""
+	adjustExtension = flags["adjust-extension"] != ""
+	trustServerNames = flags["trust-server-names"] != ""
+	allowDowngrade = flags["allow-https-downgrade"] != ""
+	if value := flags["max-redirect"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid --max-redirect value %q", value)
+		}
+		maxRedirects = n
+	}
+	if flags["no-redirect"] != "" {
+		maxRedirects = 0
+	}
+	if value := flags["backups"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid --backups value %q", value)
+		}
+		backups = n
+	}
+
+	if err := configureRequest(flags); err != nil {
+		return err
+	}
+	if err := configureAuth(flags); err != nil {
+		return err
+	}
+	if err := configureCookies(flags); err != nil {
+		return err
+	}
+	if err := configureProxy(flags); err != nil {
+		return err
+	}
+	if err := configureTLS(flags); err != nil {
+		return err
+	}
+	if err := configureChecksums(flags); err != nil {
+		return err
+	}
+
+	if value := flags["rate-limit"]; value != "" {
+		if err := setRateLimit(value); err != nil {
+			return err
+		}
+	}
+
+	if value := flags["tries"]; value != "" {
+		tries, err := strconv.Atoi(value)
+		if err != nil || tries < 0 {
+			return fmt.Errorf("invalid --tries value %q", value)
+		}
+		retry.tries = tries
+	}
+	if value := flags["retry-on"]; value != "" {
+		network, statuses, classes, err := parseRetryOn(value)
+		if err != nil {
+			return err
+		}
+		retry.network, retry.statuses, retry.classes = network, statuses, classes
+	}
+	if value := flags["waitretry"]; value != "" {
+		secs, err := strconv.ParseFloat(value, 64)
+		if err != nil || secs < 0 {
+			return fmt.Errorf("invalid --waitretry value %q", value)
+		}
+		retry.maxWait = time.Duration(secs * float64(time.Second))
+		if retry.baseWait > retry.maxWait {
+			retry.baseWait = retry.maxWait
+		}
+	}
+	if value := flags["segments"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid --segments value %q", value)
+		}
+		segments = n
+	}
+	if value := flags["input-format"]; value != "" {
+		if !inputFormats[value] {
+			return fmt.Errorf("invalid --input-format %q, expected auto, plain, jsonl or csv", value)
+		}
+		inputFormat = value
+	}
+	if value := flags["jobs"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid --jobs value %q", value)
+		}
+		jobs = n
+	}
+	if value := flags["host-jobs"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid --host-jobs value %q", value)
+		}
+		hostJobs = n
+	}
+	if value := flags["wait"]; value != "" {
+		secs, err := strconv.ParseFloat(value, 64)
+		if err != nil || secs < 0 {
+			return fmt.Errorf("invalid --wait value %q", value)
+		}
+		waitBetween = time.Duration(secs * float64(time.Second))
+	}
+	randomWait = flags["random-wait"] != ""
+	if value := flags["host-rate"]; value != "" {
+		perSec, err := strconv.ParseFloat(value, 64)
+		if err != nil || perSec < 0 {
+			return fmt.Errorf("invalid --host-rate value %q", value)
+		}
+		hostRate = perSec
+	}
+	return nil
+}
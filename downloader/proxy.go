@@ -0,0 +1,172 @@
+package downloader
+
+// proxy support - --proxy, --no-proxy and the [proxy] config section
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"wget/config"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// hostProxy routes requests for hosts matching pattern through proxy, or
+// straight to the server when proxy is nil.
+type hostProxy struct {
+	pattern string
+	proxy   *url.URL
+}
+
+// matches reports whether host matches an exact name or a "*.example.com" /
+// ".example.com" domain pattern.
+func (r hostProxy) matches(host string) bool {
+	host = strings.ToLower(host)
+	pattern := strings.ToLower(r.pattern)
+	if domain, ok := strings.CutPrefix(pattern, "*"); ok {
+		pattern = domain
+	}
+	if strings.HasPrefix(pattern, ".") {
+		return host == pattern[1:] || strings.HasSuffix(host, pattern)
+	}
+	return host == pattern
+}
+
+// parseProxyURL validates a proxy URL, defaulting to http:// when no scheme
+// is given, and adds the --proxy-user credentials if it has none.
+func parseProxyURL(raw, user, password string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %v", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", u.Scheme)
+	}
+	if u.User == nil && user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	return u, nil
+}
+
+// sectionString reads a string or list of strings from a config table.
+func sectionString(section map[string]any, key string) string {
+	switch v := section[key].(type) {
+	case string:
+		return v
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return ""
+}
+
+// proxySchemes are the request schemes with a proxy and no_proxy list each.
+var proxySchemes = []string{"http", "https"}
+
+// configureProxy installs the proxy selection of the shared transport, see
+// proxySelector.
+func configureProxy(flags map[string]string) error {
+	selector, err := proxySelector(config.Section("proxy"), flags)
+	if err != nil {
+		return err
+	}
+	transport.Proxy = selector
+	return nil
+}
+
+// proxySelector builds the proxy selection from the environment (HTTP_PROXY,
+// HTTPS_PROXY, NO_PROXY), which the [proxy] config section overrides per
+// scheme and --proxy and --no-proxy override in turn. no_proxy applies to
+// both schemes, no_proxy_http and no_proxy_https add hosts that only bypass
+// the proxy of that scheme; --no-proxy replaces all of them. Per host rules
+// from [proxy.hosts] are checked before anything else.
+func proxySelector(section map[string]any, flags map[string]string) (func(*http.Request) (*url.URL, error), error) {
+	cfg := httpproxy.FromEnvironment()
+	user, password := flags["proxy-user"], flags["proxy-password"]
+
+	if v := sectionString(section, "http"); v != "" {
+		cfg.HTTPProxy = v
+	}
+	if v := sectionString(section, "https"); v != "" {
+		cfg.HTTPSProxy = v
+	}
+	if v := sectionString(section, "no_proxy"); v != "" {
+		cfg.NoProxy = v
+	}
+	schemeNoProxy := map[string]string{}
+	for _, scheme := range proxySchemes {
+		schemeNoProxy[scheme] = sectionString(section, "no_proxy_"+scheme)
+	}
+	if v := flags["proxy"]; v != "" {
+		cfg.HTTPProxy, cfg.HTTPSProxy = v, v
+	}
+	if v := flags["no-proxy"]; v != "" {
+		cfg.NoProxy = v
+		schemeNoProxy = map[string]string{}
+	}
+
+	// validate early and apply --proxy-user to the scheme proxies
+	for _, p := range []*string{&cfg.HTTPProxy, &cfg.HTTPSProxy} {
+		if *p == "" {
+			continue
+		}
+		u, err := parseProxyURL(*p, user, password)
+		if err != nil {
+			return nil, err
+		}
+		*p = u.String()
+	}
+
+	var rules []hostProxy
+	if hosts, ok := section["hosts"].(map[string]any); ok {
+		for pattern, value := range hosts {
+			target, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("proxy rule for %q must be a string", pattern)
+			}
+			rule := hostProxy{pattern: pattern}
+			if target != "direct" && target != "" {
+				u, err := parseProxyURL(target, user, password)
+				if err != nil {
+					return nil, err
+				}
+				rule.proxy = u
+			}
+			rules = append(rules, rule)
+		}
+	}
+	// most specific (longest) patterns first
+	sort.Slice(rules, func(a, b int) bool { return len(rules[a].pattern) > len(rules[b].pattern) })
+
+	// each scheme gets its own view of the config with its no_proxy list
+	fromConfig := map[string]func(*url.URL) (*url.URL, error){}
+	for _, scheme := range proxySchemes {
+		schemeCfg := *cfg
+		if extra := schemeNoProxy[scheme]; extra != "" {
+			schemeCfg.NoProxy = strings.Trim(cfg.NoProxy+","+extra, ",")
+		}
+		fromConfig[scheme] = schemeCfg.ProxyFunc()
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		for _, rule := range rules {
+			if rule.matches(req.URL.Hostname()) {
+				return rule.proxy, nil
+			}
+		}
+		if proxyFunc, ok := fromConfig[req.URL.Scheme]; ok {
+			return proxyFunc(req.URL)
+		}
+		return nil, nil
+	}, nil
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxySelector(t *testing.T) {
+	for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "REQUEST_METHOD"} {
+		t.Setenv(env, "")
+	}
+	section := map[string]any{
+		"http":           "proxy.corp:3128",
+		"https":          "http://secure.corp:3128",
+		"no_proxy":       []any{".internal.example"},
+		"no_proxy_https": "cdn.example.com,.static.example",
+		"no_proxy_http":  []any{"plain.example.com"},
+		"hosts": map[string]any{
+			"*.artifacts.example": "socks5://bastion:1080",
+			"direct.example.com":  "direct",
+		},
+	}
+	tests := []struct {
+		name  string
+		flags map[string]string
+		url   string
+		want  string // "" for a direct connection
+	}{
+		{name: "http proxy", url: "http://example.com/a", want: "http://proxy.corp:3128"},
+		{name: "https proxy", url: "https://example.com/a", want: "http://secure.corp:3128"},
+		{name: "no_proxy for both schemes", url: "https://www.internal.example/", want: ""},
+		{name: "no_proxy for both schemes over http", url: "http://www.internal.example/", want: ""},
+		{name: "https only bypass", url: "https://cdn.example.com/a", want: ""},
+		{name: "https only bypass keeps http proxy", url: "http://cdn.example.com/a", want: "http://proxy.corp:3128"},
+		{name: "https only domain", url: "https://img.static.example/a", want: ""},
+		{name: "http only bypass", url: "http://plain.example.com/", want: ""},
+		{name: "http only bypass keeps https proxy", url: "https://plain.example.com/", want: "http://secure.corp:3128"},
+		{name: "host rule", url: "https://eu.artifacts.example/x", want: "socks5://bastion:1080"},
+		{name: "host rule before no_proxy", url: "https://direct.example.com/", want: ""},
+		{
+			name: "--proxy-user on scheme proxies", flags: map[string]string{"proxy-user": "bob", "proxy-password": "pw"},
+			url: "http://example.com/", want: "http://bob:pw@proxy.corp:3128",
+		},
+		{
+			name: "--no-proxy replaces every list", flags: map[string]string{"no-proxy": "other.example"},
+			url: "https://cdn.example.com/a", want: "http://secure.corp:3128",
+		},
+		{
+			name: "--no-proxy", flags: map[string]string{"no-proxy": "example.com"},
+			url: "https://example.com/a", want: "",
+		},
+		{
+			name: "--proxy for both schemes", flags: map[string]string{"proxy": "socks5://local:1080"},
+			url: "http://example.com/", want: "socks5://local:1080",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := proxySelector(section, tt.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proxy, err := selector(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if proxy != nil {
+				got = proxy.String()
+			}
+			if got != tt.want {
+				t.Errorf("proxy for %s = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxySelectorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		section map[string]any
+		flags   map[string]string
+	}{
+		{name: "bad scheme", flags: map[string]string{"proxy": "ftp://proxy:21"}},
+		{name: "bad host rule", section: map[string]any{"hosts": map[string]any{"a.example": 1}}},
+		{name: "bad host proxy", section: map[string]any{"hosts": map[string]any{"a.example": "gopher://x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := proxySelector(tt.section, tt.flags); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
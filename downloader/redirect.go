@@ -0,0 +1,61 @@
+package downloader
+
+// redirect policy - --max-redirect, downgrade protection and hop logging
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"wget/logger"
+)
+
+var (
+	// maxRedirects is how many redirects one request follows (--max-redirect)
+	maxRedirects = 10
+	// allowDowngrade lets https URLs redirect to plain http
+	allowDowngrade bool
+	// trustServerNames names files after the URL at the end of the redirects
+	trustServerNames bool
+
+	// finalURLs maps a requested URL to where its redirects ended up
+	finalURLs sync.Map
+)
+
+// checkRedirect is called before following a redirect. It enforces the
+// redirect limit and refuses https to http downgrades. Credentials are only
+// ever sent to the host they were meant for.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		if maxRedirects == 0 {
+			// hand the 3xx itself back to the caller
+			return http.ErrUseLastResponse
+		}
+		return fmt.Errorf("%d redirections exceeded", maxRedirects)
+	}
+	prev := via[len(via)-1]
+	if prev.URL.Scheme == "https" && req.URL.Scheme == "http" && !allowDowngrade {
+		return fmt.Errorf("refusing redirect from https to http (%s), use --allow-https-downgrade to follow it", req.URL)
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+	}
+
+	// probes repeat the hops of the download itself, log those only once
+	if req.Method != http.MethodHead {
+		logger.Printf("Redirected (%s) %s -> %s\n", req.Response.Status, prev.URL, req.URL)
+		logger.Emit(logger.Event{Event: "redirect", URL: prev.URL.String(), Status: req.Response.StatusCode, Location: req.URL.String()})
+	}
+	finalURLs.Store(via[0].URL.String(), req.URL.String())
+	return nil
+}
+
+// FinalURL returns the URL a request for fileURL was last redirected to, or
+// fileURL itself when it wasn't redirected. The mirrorer resolves relative
+// links of a page against it.
+func FinalURL(fileURL string) string {
+	if final, ok := finalURLs.Load(fileURL); ok {
+		return final.(string)
+	}
+	return fileURL
+}
@@ -0,0 +1,139 @@
+package downloader
+
+// shared request builder - headers, user agent, referer, method and body
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// defaultUserAgent is sent unless --user-agent or a User-Agent header is given.
+const defaultUserAgent = "get-with-a-w/1.0"
+
+var (
+	// extraHeaders are added to every request, set by --header
+	extraHeaders = http.Header{}
+	// userAgent is sent as User-Agent, set by --user-agent
+	userAgent = defaultUserAgent
+	// referer is sent as Referer, set by --referer
+	referer string
+	// method is the HTTP method of downloads, set by --method or implied by --post-*
+	method = http.MethodGet
+	// body is sent with every download request when set
+	body []byte
+)
+
+// newRequest builds a download request for fileURL with the configured
+// method, body and headers. Every fetch path goes through it so all of
+// them send the same headers. ctx may carry the options of an -i entry, see
+// withOptions.
+func newRequest(ctx context.Context, fileURL string) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, fileURL, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, fileURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	applyHeaders(req)
+	return req, nil
+}
+
+// newPlainRequest builds an auxiliary request (probes, robots.txt) that
+// carries the configured headers but never the download method or body.
+func newPlainRequest(ctx context.Context, reqMethod, fileURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, reqMethod, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyHeaders(req)
+	return req, nil
+}
+
+func applyHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent)
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	if body != nil && extraHeaders.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	// headers of an -i entry come last and win over --header
+	for _, headers := range []http.Header{extraHeaders, optionsFrom(req.Context()).headers} {
+		for name, values := range headers {
+			req.Header.Del(name)
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+}
+
+// parseHeaders parses newline separated "Name: value" lines from --header.
+func parseHeaders(lines string) (http.Header, error) {
+	headers := http.Header{}
+	for _, line := range strings.Split(lines, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected 'Name: value'", line)
+		}
+		headers.Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// configureRequest applies the request related flags.
+func configureRequest(flags map[string]string) error {
+	if value := flags["header"]; value != "" {
+		headers, err := parseHeaders(value)
+		if err != nil {
+			return err
+		}
+		extraHeaders = headers
+	}
+	if value := flags["user-agent"]; value != "" {
+		userAgent = value
+	}
+	referer = flags["referer"]
+
+	switch {
+	case flags["post-data"] != "":
+		body = []byte(flags["post-data"])
+	case flags["post-file"] != "":
+		data, err := os.ReadFile(flags["post-file"])
+		if err != nil {
+			return fmt.Errorf("reading --post-file: %v", err)
+		}
+		body = data
+	case flags["body-file"] != "":
+		data, err := os.ReadFile(flags["body-file"])
+		if err != nil {
+			return fmt.Errorf("reading --body-file: %v", err)
+		}
+		body = data
+	}
+
+	method = http.MethodGet
+	if body != nil && flags["body-file"] == "" {
+		method = http.MethodPost
+	}
+	if value := flags["method"]; value != "" {
+		method = strings.ToUpper(value)
+	}
+	if flags["body-file"] != "" && flags["method"] == "" {
+		return fmt.Errorf("--body-file requires --method")
+	}
+	return nil
+}
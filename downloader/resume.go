@@ -0,0 +1,357 @@
+package downloader
+
+// resume support for -c / --continue and atomic .part files
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"wget/logger"
+)
+
+// continueMode is set by -c / --continue
+var continueMode bool
+
+// partSuffix marks a download in progress. Data goes to "<name>.part" and
+// is renamed into place only once complete, so a truncated file never looks
+// like a finished one.
+const partSuffix = ".part"
+
+func partPath(target string) string {
+	return target + partSuffix
+}
+
+// errAlreadyComplete is returned when the local file already holds every byte
+// the server has to offer.
+var errAlreadyComplete = errors.New("the file is already fully retrieved; nothing to do")
+
+// resumeState keeps the validators of a partial download so a later run can
+// make sure the server still serves the same file before appending to it.
+type resumeState struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// statePath returns the hidden sidecar file holding the resume state of target.
+func statePath(target string) string {
+	dir, base := filepath.Split(target)
+	return filepath.Join(dir, "."+base+".wget-state")
+}
+
+func loadResumeState(target string) resumeState {
+	var st resumeState
+	data, err := os.ReadFile(statePath(target))
+	if err == nil {
+		json.Unmarshal(data, &st)
+	}
+	return st
+}
+
+func saveResumeState(target string, resp *http.Response) {
+	st := resumeState{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if st.ETag == "" && st.LastModified == "" {
+		os.Remove(statePath(target))
+		return
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	os.WriteFile(statePath(target), data, 0644)
+}
+
+// clearResumeState removes the sidecar once a download has completed.
+func clearResumeState(target string) {
+	os.Remove(statePath(target))
+}
+
+// partialOffset returns the size of an existing partial download of target,
+// or 0 when continue mode is off or there is nothing to resume. The .part
+// file is preferred; a file at target itself (e.g. left by another tool) is
+// resumed too.
+func partialOffset(target string) int64 {
+	if !continueMode {
+		return 0
+	}
+	for _, path := range []string{partPath(target), target} {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	}
+	return 0
+}
+
+// promotePartial renames a .part file that turned out to be complete.
+func promotePartial(target string) error {
+	if _, err := os.Stat(partPath(target)); err != nil {
+		return nil
+	}
+	if err := os.Rename(partPath(target), target); err != nil {
+		return err
+	}
+	finishState(target)
+	return nil
+}
+
+// discardPartial removes the .part file and resume state after a permanent
+// failure, such as a 404 or a checksum mismatch.
+func discardPartial(target string) {
+	os.Remove(partPath(target))
+	clearResumeState(target)
+}
+
+// finishState drops the resume state of a completed download. With -N the
+// validators are kept instead, as the ETag and Last-Modified the next run
+// asks the server about, and the file gets the server's mtime.
+func finishState(target string) {
+	if !timestamping {
+		clearResumeState(target)
+		return
+	}
+	applyServerTime(target)
+}
+
+// permanentStatus reports whether a failed status won't change on a later
+// run, so keeping a partial download for it is pointless.
+func permanentStatus(code int) bool {
+	return code >= 400 && code < 500 && !retry.retryStatus(code)
+}
+
+// getResumable issues a GET for fileURL, asking only for the bytes missing
+// from target when continue mode is on. The returned offset is where the body
+// starts in the file; it is 0 whenever the server sends the whole file, in
+// which case the caller must truncate target before writing.
+func getResumable(ctx context.Context, fileURL, target string) (*http.Response, int64, error) {
+	return getFrom(ctx, fileURL, target, partialOffset(target))
+}
+
+func getFrom(ctx context.Context, fileURL, target string, offset int64) (*http.Response, int64, error) {
+	if offset == 0 {
+		req, err := newRequest(ctx, fileURL)
+		if err != nil {
+			return nil, 0, err
+		}
+		if timestamping {
+			addConditions(req, target)
+		}
+		resp, err := doWithRetry(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.StatusCode == http.StatusNotModified || (timestamping && resp.StatusCode == http.StatusOK && upToDate(resp, target)) {
+			resp.Body.Close()
+			return nil, 0, errNotModified
+		}
+		if resp.StatusCode == http.StatusOK {
+			saveResumeState(target, resp)
+		}
+		return resp, 0, nil
+	}
+
+	st := loadResumeState(target)
+	req, err := newRequest(ctx, fileURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	if st.ETag != "" && !strings.HasPrefix(st.ETag, "W/") {
+		req.Header.Set("If-Range", st.ETag)
+	} else if st.LastModified != "" {
+		req.Header.Set("If-Range", st.LastModified)
+	}
+
+	resp, err := doWithRetry(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if err := validatePartial(resp, st, offset); err != nil {
+			// The server answered with a range we cannot safely append, so
+			// drop the partial file and fetch everything again.
+			resp.Body.Close()
+			logger.Printf("Cannot resume (%v), restarting download\n", err)
+			clearResumeState(target)
+			return getFrom(ctx, fileURL, target, 0)
+		}
+		return resp, offset, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		total, ok := contentRangeTotal(resp.Header.Get("Content-Range"))
+		if ok && total == offset {
+			resp.Body.Close()
+			return nil, offset, errAlreadyComplete
+		}
+	case http.StatusOK:
+		logger.Println("Server does not support resuming, restarting download")
+		saveResumeState(target, resp)
+	}
+	return resp, 0, nil
+}
+
+// copyBody copies the body of resp, which starts offset bytes into the file,
+// to w. When the connection drops mid-body the missing bytes are asked for
+// with a Range request, within --tries, as long as the server still serves
+// the same file. It returns how many bytes were written.
+func copyBody(ctx context.Context, fileURL string, w io.Writer, resp *http.Response, offset int64) (int64, error) {
+	st := resumeState{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	body := resp.Body
+	var written int64
+	for attempt := 1; ; attempt++ {
+		n, err := io.Copy(w, limitReader(ctx, body))
+		written += n
+		if body != resp.Body {
+			body.Close()
+		}
+		if err == nil {
+			return written, nil
+		}
+		last := retry.tries > 0 && attempt >= retry.tries
+		if last || !retry.network || method != http.MethodGet || !(retryableError(err) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return written, err
+		}
+
+		wait := retry.backoff(attempt)
+		logger.Printf("Connection lost after %d bytes (%v), resuming in %s\n", offset+written, err, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return written, ctx.Err()
+		}
+
+		req, rerr := newRequest(ctx, fileURL)
+		if rerr != nil {
+			return written, rerr
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset+written))
+		if st.ETag != "" && !strings.HasPrefix(st.ETag, "W/") {
+			req.Header.Set("If-Range", st.ETag)
+		} else if st.LastModified != "" {
+			req.Header.Set("If-Range", st.LastModified)
+		}
+		next, rerr := doWithRetry(req)
+		if rerr != nil {
+			return written, fmt.Errorf("%v (resuming failed: %v)", err, rerr)
+		}
+		if next.StatusCode != http.StatusPartialContent {
+			next.Body.Close()
+			return written, fmt.Errorf("%v (resuming failed: server answered %s)", err, next.Status)
+		}
+		if verr := validatePartial(next, st, offset+written); verr != nil {
+			next.Body.Close()
+			return written, fmt.Errorf("%v (resuming failed: %v)", err, verr)
+		}
+		body = next.Body
+	}
+}
+
+// validatePartial checks that a 206 response starts where the partial file
+// ends and still describes the same remote file.
+func validatePartial(resp *http.Response, st resumeState, offset int64) error {
+	start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	if start != offset {
+		return fmt.Errorf("server sent range starting at %d, expected %d", start, offset)
+	}
+	if etag := resp.Header.Get("ETag"); st.ETag != "" && etag != "" && etag != st.ETag {
+		return fmt.Errorf("remote file changed (ETag %s != %s)", etag, st.ETag)
+	}
+	if lm := resp.Header.Get("Last-Modified"); st.LastModified != "" && lm != "" && lm != st.LastModified {
+		return fmt.Errorf("remote file changed (Last-Modified %s)", lm)
+	}
+	return nil
+}
+
+// contentRangeStart parses the first byte position of "bytes 100-199/200".
+func contentRangeStart(header string) (int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid Content-Range %q", header)
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	return strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+}
+
+// contentRangeTotal parses the complete length of "bytes */200" style headers.
+func contentRangeTotal(header string) (int64, bool) {
+	_, total, ok := strings.Cut(header, "/")
+	if !ok || total == "*" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	return n, err == nil
+}
+
+// openTarget opens the .part file of target for writing. With a non-zero
+// offset the existing bytes are kept and writing continues after them.
+func openTarget(target string, offset int64) (*os.File, error) {
+	part := partPath(target)
+	if offset == 0 {
+		return os.Create(part)
+	}
+	if _, err := os.Stat(part); os.IsNotExist(err) {
+		if err := os.Rename(target, part); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(part, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// commitTarget finishes a download written to a .part file: the data is
+// flushed to disk, verified against expected (see verifyChecksum) and renamed
+// into place. The returned file is the final one, opened for reading and
+// writing.
+func commitTarget(file *os.File, target, expected string) (*os.File, error) {
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error syncing file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("error closing file: %v", err)
+	}
+	if err := verifyChecksum(file.Name(), target, expected); err != nil {
+		discardPartial(target)
+		return nil, err
+	}
+	if err := rotateBackups(target); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), target); err != nil {
+		return nil, fmt.Errorf("error moving %s into place: %v", file.Name(), err)
+	}
+	finishState(target)
+	if err := recordManifest(target); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(target, os.O_RDWR, 0644)
+}
@@ -0,0 +1,122 @@
+package downloader
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{"bytes 100-199/200", 100, false},
+		{"bytes 0-0/1", 0, false},
+		{"bytes 100-199/*", 100, false},
+		{"bytes  7-9/10", 7, false},
+		{"", 0, true},
+		{"100-199/200", 0, true},
+		{"items 100-199/200", 0, true},
+		{"bytes */200", 0, true},
+		{"bytes abc-199/200", 0, true},
+		{"bytes -199/200", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := contentRangeStart(tt.header)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("contentRangeStart(%q) = %d, %v, want %d, error %v", tt.header, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestContentRangeTotal(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		ok     bool
+	}{
+		{"bytes */200", 200, true},
+		{"bytes 100-199/200", 200, true},
+		{"bytes 100-199/*", 0, false},
+		{"bytes 100-199", 0, false},
+		{"bytes */x", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := contentRangeTotal(tt.header); got != tt.want || ok != tt.ok {
+			t.Errorf("contentRangeTotal(%q) = %d, %v, want %d, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidatePartial(t *testing.T) {
+	const lastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+	tests := []struct {
+		name    string
+		state   resumeState
+		headers map[string]string
+		wantErr string
+	}{
+		{
+			name:    "matching etag",
+			state:   resumeState{ETag: `"v1"`},
+			headers: map[string]string{"Content-Range": "bytes 100-199/200", "ETag": `"v1"`},
+		},
+		{
+			name:    "matching last modified",
+			state:   resumeState{LastModified: lastModified},
+			headers: map[string]string{"Content-Range": "bytes 100-199/200", "Last-Modified": lastModified},
+		},
+		{
+			name:    "no validators to compare",
+			headers: map[string]string{"Content-Range": "bytes 100-199/200", "ETag": `"v2"`},
+		},
+		{
+			name:    "server leaves validators out",
+			state:   resumeState{ETag: `"v1"`, LastModified: lastModified},
+			headers: map[string]string{"Content-Range": "bytes 100-199/200"},
+		},
+		{
+			name:    "wrong offset",
+			headers: map[string]string{"Content-Range": "bytes 0-199/200"},
+			wantErr: "server sent range starting at 0, expected 100",
+		},
+		{
+			name:    "missing content range",
+			headers: map[string]string{},
+			wantErr: "missing or invalid Content-Range",
+		},
+		{
+			name:    "changed etag",
+			state:   resumeState{ETag: `"v1"`},
+			headers: map[string]string{"Content-Range": "bytes 100-199/200", "ETag": `"v2"`},
+			wantErr: "remote file changed (ETag",
+		},
+		{
+			name:    "changed last modified",
+			state:   resumeState{LastModified: lastModified},
+			headers: map[string]string{"Content-Range": "bytes 100-199/200", "Last-Modified": "Thu, 22 Oct 2015 07:28:00 GMT"},
+			wantErr: "remote file changed (Last-Modified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusPartialContent, Header: http.Header{}}
+			for name, value := range tt.headers {
+				resp.Header.Set(name, value)
+			}
+			err := validatePartial(resp, tt.state, 100)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePartial() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePartial() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
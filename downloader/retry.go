@@ -0,0 +1,197 @@
+package downloader
+
+// retries with exponential backoff - the --tries / --retry-on / --waitretry flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"wget/logger"
+)
+
+// retryPolicy decides which failures are worth another attempt.
+type retryPolicy struct {
+	tries    int           // total attempts, 0 means unlimited
+	network  bool          // retry connection level errors
+	statuses map[int]bool  // exact status codes to retry
+	classes  map[int]bool  // status classes to retry, e.g. 5 for 5xx
+	maxWait  time.Duration // upper bound for the exponential backoff
+	baseWait time.Duration // delay before the first retry
+}
+
+const defaultRetryOn = "net,408,429,5xx"
+
+var retry = retryPolicy{
+	tries:    3,
+	network:  true,
+	statuses: map[int]bool{408: true, 429: true},
+	classes:  map[int]bool{5: true},
+	maxWait:  10 * time.Second,
+	baseWait: time.Second,
+}
+
+// parseRetryOn parses a comma separated list such as "net,429,5xx".
+func parseRetryOn(spec string) (bool, map[int]bool, map[int]bool, error) {
+	network := false
+	statuses := map[int]bool{}
+	classes := map[int]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch {
+		case item == "":
+		case item == "net":
+			network = true
+		case len(item) == 3 && strings.HasSuffix(item, "xx") && item[0] >= '1' && item[0] <= '5':
+			classes[int(item[0]-'0')] = true
+		default:
+			code, err := strconv.Atoi(item)
+			if err != nil || code < 100 || code > 599 {
+				return false, nil, nil, fmt.Errorf("invalid --retry-on entry %q", item)
+			}
+			statuses[code] = true
+		}
+	}
+	return network, statuses, classes, nil
+}
+
+func (p retryPolicy) retryStatus(code int) bool {
+	return p.statuses[code] || p.classes[code/100]
+}
+
+// retryableError reports whether err looks like a transient network failure.
+func retryableError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// backoff returns the delay before the given retry (1 for the first retry):
+// exponential growth capped at maxWait, with jitter so parallel downloads
+// don't retry in lockstep.
+func (p retryPolicy) backoff(retryNum int) time.Duration {
+	wait := p.baseWait << (retryNum - 1)
+	if wait <= 0 || wait > p.maxWait {
+		wait = p.maxWait
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// doWithRetry sends req, retrying transient failures according to the
+// current policy. Responses with a status that is not retryable are handed
+// back to the caller untouched.
+func doWithRetry(req *http.Request) (*http.Response, error) {
+	// credentials follow the host the user asked for, not each request's
+	origin := optionsFrom(req.Context()).origin
+	for attempt := 1; ; attempt++ {
+		try := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try.Body = body
+		}
+
+		if err := paceHost(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+		applyAuth(try, origin)
+		resp, err := httpClient.Do(try)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized {
+			if authed := answerChallenge(try, resp, origin); authed != nil {
+				io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+				resp.Body.Close()
+				resp, err = httpClient.Do(authed)
+			}
+		}
+		last := retry.tries > 0 && attempt >= retry.tries
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if last || !retry.network || !retryableError(err) {
+				return nil, explainTLSError(err)
+			}
+			wait = retry.backoff(attempt)
+			logger.Printf("Request to %s failed: %v\n", req.URL, err)
+		case retry.retryStatus(resp.StatusCode):
+			if last {
+				return resp, nil
+			}
+			wait = retry.backoff(attempt)
+			if d, ok := retryAfter(resp); ok {
+				// --waitretry caps the server's wish as well
+				wait = min(d, retry.maxWait)
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			logger.Printf("Server answered %s for %s\n", resp.Status, req.URL)
+		default:
+			return resp, nil
+		}
+
+		if retry.tries > 0 {
+			logger.Printf("Retrying in %s (attempt %d of %d)\n", wait.Round(time.Millisecond), attempt+1, retry.tries)
+		} else {
+			logger.Printf("Retrying in %s (attempt %d)\n", wait.Round(time.Millisecond), attempt+1)
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// Get fetches fileURL through the same request path as downloads, retries
+// included, for callers that need the body in memory (robots.txt, ...).
+// origin is the host the user asked for, the only one sent credentials from
+// the command line.
+func Get(fileURL, origin string) (*http.Response, error) {
+	ctx := withOptions(context.Background(), fileOptions{origin: origin})
+	req, err := newPlainRequest(ctx, http.MethodGet, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	return doWithRetry(req)
+}
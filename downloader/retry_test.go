@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	tests := []struct {
+		spec     string
+		network  bool
+		statuses map[int]bool
+		classes  map[int]bool
+		wantErr  bool
+	}{
+		{spec: defaultRetryOn, network: true, statuses: map[int]bool{408: true, 429: true}, classes: map[int]bool{5: true}},
+		{spec: " NET , 503,4XX,", network: true, statuses: map[int]bool{503: true}, classes: map[int]bool{4: true}},
+		{spec: "502,502", statuses: map[int]bool{502: true}, classes: map[int]bool{}},
+		{spec: "", statuses: map[int]bool{}, classes: map[int]bool{}},
+		{spec: "6xx", wantErr: true},
+		{spec: "0xx", wantErr: true},
+		{spec: "99", wantErr: true},
+		{spec: "600", wantErr: true},
+		{spec: "timeout", wantErr: true},
+		{spec: "5x", wantErr: true},
+	}
+	for _, tt := range tests {
+		network, statuses, classes, err := parseRetryOn(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRetryOn(%q) accepted", tt.spec)
+			}
+			continue
+		}
+		if err != nil || network != tt.network || !reflect.DeepEqual(statuses, tt.statuses) || !reflect.DeepEqual(classes, tt.classes) {
+			t.Errorf("parseRetryOn(%q) = %v, %v, %v, %v, want %v, %v, %v",
+				tt.spec, network, statuses, classes, err, tt.network, tt.statuses, tt.classes)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		max    time.Duration
+		ok     bool
+	}{
+		{name: "missing", header: ""},
+		{name: "seconds", header: "120", min: 2 * time.Minute, max: 2 * time.Minute, ok: true},
+		{name: "zero", header: "0", ok: true},
+		{name: "padded", header: " 5 ", min: 5 * time.Second, max: 5 * time.Second, ok: true},
+		{name: "negative", header: "-5"},
+		{name: "fraction", header: "1.5"},
+		{name: "garbage", header: "soon"},
+		{
+			name: "future date", header: time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat),
+			min: 85 * time.Second, max: 90 * time.Second, ok: true,
+		},
+		{name: "past date", header: "Wed, 21 Oct 2015 07:28:00 GMT", ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			got, ok := retryAfter(resp)
+			if ok != tt.ok || got < tt.min || got > tt.max {
+				t.Errorf("retryAfter(%q) = %v, %v, want %v..%v, %v", tt.header, got, ok, tt.min, tt.max, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := retryPolicy{baseWait: time.Second, maxWait: 10 * time.Second}
+	tests := []struct {
+		retryNum int
+		max      time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{80, 10 * time.Second}, // the shift overflows
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := p.backoff(tt.retryNum); got < tt.max/2 || got > tt.max {
+				t.Errorf("backoff(%d) = %v, want %v..%v", tt.retryNum, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
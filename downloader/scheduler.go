@@ -1,48 +1,224 @@
 package downloader
 
 import (
-	"bufio"
+	"context"
 	"fmt"
+	"math/rand"
+	"net/url"
 	"os"
-	"strings"
 	"sync"
+	"time"
+
+	"wget/logger"
+
+	"golang.org/x/time/rate"
 )
 
-// Handles the case when -i flag is set
-func FileList(inputFile string) {
-	// convert interface to string
-	file, err := os.Open(inputFile)
-	if err != nil {
-		fmt.Printf("Failed to open file: %v\n", err)
-		os.Exit(1)
+var (
+	// jobs caps how many -i downloads run at once, set by --jobs
+	jobs = 4
+	// hostJobs caps concurrent downloads against one host, 0 for no cap
+	hostJobs = 4
+	// waitBetween is the pause between two requests to the same host (--wait)
+	waitBetween time.Duration
+	// randomWait varies waitBetween between 0.5 and 1.5 times (--random-wait)
+	randomWait bool
+	// hostRate caps requests per second to one host, 0 for no cap (--host-rate)
+	hostRate float64
+)
+
+// hostState is the politeness bookkeeping for a single host.
+type hostState struct {
+	slots chan struct{} // concurrent downloads, nil when uncapped
+	pace  *rate.Limiter // requests per second, nil when uncapped
+
+	mu    sync.Mutex
+	next  time.Time     // earliest start of the next request because of --wait
+	delay time.Duration // per host minimum wait, e.g. a robots.txt Crawl-delay
+}
+
+// hostScheduler is the host-keyed scheduler every fetch goes through: the
+// downloader paces each request and -i / --mirror hold a slot per download.
+type hostScheduler struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+var scheduler = &hostScheduler{hosts: map[string]*hostState{}}
+
+func (s *hostScheduler) state(host string) *hostState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.hosts[host]
+	if !ok {
+		st = &hostState{}
+		if hostJobs > 0 {
+			st.slots = make(chan struct{}, hostJobs)
+		}
+		if hostRate > 0 {
+			st.pace = rate.NewLimiter(rate.Limit(hostRate), 1)
+		}
+		s.hosts[host] = st
 	}
-	defer file.Close()
+	return st
+}
 
-	// WaitGroup to wait for all the goroutines to finish
-	var wg sync.WaitGroup
+// AcquireHost blocks until a download slot for host is free and returns the
+// func releasing it. -i and --mirror wrap every download in it so no host
+// sees more than --host-jobs transfers at once.
+func AcquireHost(host string) func() {
+	st := scheduler.state(host)
+	if st.slots == nil {
+		return func() {}
+	}
+	st.slots <- struct{}{}
+	return func() { <-st.slots }
+}
 
-	// Create a new buffered reader
-	reader := bufio.NewReader(file)
+// SetHostDelay makes every request to host wait at least delay after the
+// previous one, on top of --wait. The mirrorer uses it for Crawl-delay.
+func SetHostDelay(host string, delay time.Duration) {
+	st := scheduler.state(host)
+	st.mu.Lock()
+	st.delay = delay
+	st.mu.Unlock()
+}
 
-	for {
+// paceHost delays a request to host according to --host-rate, --wait and
+// --random-wait. It is called before every HTTP request, retries included.
+func paceHost(ctx context.Context, host string) error {
+	st := scheduler.state(host)
+	if st.pace != nil {
+		if err := st.pace.Wait(ctx); err != nil {
+			return err
+		}
+	}
 
-		line, _, err := reader.ReadLine()
-		if err != nil {
-			if err.Error() != "EOF" {
-				msg := fmt.Sprintf("Failed to read file: %v", err)
-				print(msg)
-				// logger.Log(msg)
-			}
-			break
+	st.mu.Lock()
+	delay := waitBetween
+	if st.delay > delay {
+		delay = st.delay
+	}
+	if delay <= 0 {
+		st.mu.Unlock()
+		return nil
+	}
+	if randomWait {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay)+1))
+	}
+	start := time.Now()
+	if st.next.After(start) {
+		start = st.next
+	}
+	st.next = start.Add(delay)
+	st.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(start)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// listEntry is one entry of an -i input file, see parseInputList.
+type listEntry struct {
+	url  string
+	opts fileOptions
+}
+
+// listResult is the outcome of one entry of an -i input file.
+type listResult struct {
+	url string
+	err error
+}
+
+// Handles the case when -i flag is set. URLs are downloaded by a bounded
+// pool of workers; a summary in input order is printed at the end and an
+// error is returned if any download failed. An inputFile of "-" reads the
+// list from stdin.
+func FileList(inputFile string) error {
+	file, name := os.Stdin, "<stdin>"
+	if inputFile != stdoutName {
+		var err error
+		if file, err = os.Open(inputFile); err != nil {
+			return fmt.Errorf("failed to open file: %v", err)
 		}
+		defer file.Close()
+		name = inputFile
+	}
+
+	// every entry is checked before the first download starts
+	links, err := parseInputList(file, name, inputFormat)
+	if err != nil {
+		return err
+	}
 
+	results := make([]listResult, len(links))
+	queue := make(chan int)
+
+	workers := jobs
+	if workers < 1 || workers > len(links) {
+		workers = len(links)
+	}
+
+	// WaitGroup to wait for all the workers to finish
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(link string) {
+		go func() {
 			defer wg.Done()
-			print("Testing link: ", link)
-			DownloadFile(strings.TrimSuffix(link, "\n"), false)
-		}(string(line))
+			for i := range queue {
+				entry := links[i]
+				results[i] = listResult{url: entry.url, err: downloadListEntry(entry)}
+			}
+		}()
 	}
-
+	for i := range links {
+		queue <- i
+	}
+	close(queue)
 	wg.Wait()
+
+	return summarize(results)
+}
+
+// downloadListEntry downloads a single -i entry while holding a host slot.
+func downloadListEntry(entry listEntry) error {
+	parsed, err := url.Parse(entry.url)
+	if err != nil {
+		return err
+	}
+	release := AcquireHost(parsed.Host)
+	defer release()
+
+	file, err := downloadFile(entry.url, false, entry.opts)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// summarize prints the outcome of every entry in input order.
+func summarize(results []listResult) error {
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+	}
+
+	logger.Infof("\nDownloaded %d of %d URLs\n", len(results)-failed, len(results))
+	for _, r := range results {
+		if r.err != nil {
+			logger.Infof("  FAILED  %s: %v\n", r.url, r.err)
+		} else {
+			logger.Infof("  OK      %s\n", r.url)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(results))
+	}
+	return nil
 }
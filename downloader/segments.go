@@ -0,0 +1,176 @@
+package downloader
+
+// segmented parallel download of a single file - the --segments flag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"wget/logger"
+)
+
+// segments is the number of parallel connections used for one file, set by
+// --segments. Values below 2 keep the classic single stream download.
+var segments = 1
+
+// minSegmentSize keeps small files from being split into tiny ranges.
+const minSegmentSize = 1 << 20 // 1 MB
+
+// probeRanges asks the server for the size of fileURL and whether it accepts
+// byte ranges. The returned validator is used as If-Range for every segment.
+// The HEAD response itself is returned for its size and headers.
+func probeRanges(ctx context.Context, fileURL string) (*http.Response, string, bool) {
+	req, err := newPlainRequest(ctx, http.MethodHead, fileURL)
+	if err != nil {
+		return nil, "", false
+	}
+	resp, err := doWithRetry(req)
+	if err != nil {
+		return nil, "", false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 {
+		return nil, "", false
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" || validator[0] == 'W' {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	return resp, validator, true
+}
+
+// downloadSegmented fetches fileURL into the .part file of target using
+// several concurrent range requests written straight into a preallocated
+// file, which the caller commits. The boolean result is
+// false when segmenting is disabled or not possible for this URL, in which
+// case the caller falls back to a normal download.
+func downloadSegmented(ctx context.Context, fileURL, target string, logf func(string, ...any), showBar bool) (*os.File, bool, error) {
+	// with -N an existing file is checked by the conditional single request
+	if segments < 2 || method != http.MethodGet || body != nil || partialOffset(target) > 0 ||
+		(timestamping && fileExists(target)) {
+		return nil, false, nil
+	}
+	probe, validator, ok := probeRanges(ctx, fileURL)
+	if !ok || probe.ContentLength < 2*minSegmentSize {
+		return nil, false, nil
+	}
+	size := probe.ContentLength
+	if timestamping {
+		saveResumeState(target, probe)
+	}
+
+	count := int64(segments)
+	if max := size / minSegmentSize; count > max {
+		count = max
+	}
+	logf("Content size: %d [~%.2fMB]", size, float64(size)/(1024*1024))
+	logf("Downloading in %d segments", count)
+
+	file, err := openTarget(target, 0)
+	if err != nil {
+		return nil, true, fmt.Errorf("error creating file: %v", err)
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		discardPartial(target)
+		return nil, true, fmt.Errorf("error preallocating file: %v", err)
+	}
+
+	var bar io.Writer = io.Discard
+	if showBar {
+		bar = newBar(size)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	chunk := size / count
+	for i := int64(0); i < count; i++ {
+		start := i * chunk
+		end := start + chunk - 1
+		if i == count-1 {
+			end = size - 1
+		}
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := fetchSegment(ctx, fileURL, validator, file, start, end, bar); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		// a preallocated file has holes, it can't be resumed with -c
+		file.Close()
+		discardPartial(target)
+		return nil, true, firstErr
+	}
+	return file, true, nil
+}
+
+// fetchSegment downloads bytes start..end (inclusive) into file, resuming the
+// range after a dropped connection as long as retries are left. Like
+// copyBody, it waits retry.backoff between attempts.
+func fetchSegment(ctx context.Context, fileURL, validator string, file *os.File, start, end int64, bar io.Writer) error {
+	first := start
+	for attempt := 1; ; attempt++ {
+		req, err := newPlainRequest(ctx, http.MethodGet, fileURL)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+
+		resp, err := doWithRetry(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return fmt.Errorf("segment %d-%d: unexpected status %s", first, end, resp.Status)
+		}
+		if got, err := contentRangeStart(resp.Header.Get("Content-Range")); err != nil || got != start {
+			resp.Body.Close()
+			return fmt.Errorf("segment %d-%d: server sent the wrong range", first, end)
+		}
+
+		reader := limitReader(ctx, resp.Body)
+		writer := io.MultiWriter(io.NewOffsetWriter(file, start), bar)
+		n, err := io.Copy(writer, io.LimitReader(reader, end-start+1))
+		resp.Body.Close()
+		start += n
+		if err == nil && start > end {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("connection closed early")
+		}
+		if (retry.tries > 0 && attempt >= retry.tries) || !retry.network {
+			return fmt.Errorf("segment %d-%d: %v", first, end, err)
+		}
+
+		wait := retry.backoff(attempt)
+		logger.Verbosef("Segment %d-%d lost at byte %d (%v), resuming in %s\n", first, end, start, err, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchSegmentRetries(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 10))
+	tests := []struct {
+		name    string
+		drops   int // responses cut short before a full one
+		tries   int
+		wantErr string
+	}{
+		{name: "resumed", drops: 2, tries: 3},
+		{name: "tries exhausted", drops: 5, tries: 2, wantErr: "segment 0-99: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				requests []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests = append(requests, r.Header.Get("Range"))
+				n := len(requests)
+				mu.Unlock()
+				var start, end int
+				fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+				w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+				w.WriteHeader(http.StatusPartialContent)
+				if n <= tt.drops {
+					w.Write(data[start : start+10])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				w.Write(data[start : end+1])
+			}))
+			defer srv.Close()
+
+			defer func(orig retryPolicy) { retry = orig }(retry)
+			retry.tries, retry.network = tt.tries, true
+			retry.baseWait, retry.maxWait = 40*time.Millisecond, 40*time.Millisecond
+
+			file, err := os.Create(filepath.Join(t.TempDir(), "segment"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			began := time.Now()
+			err = fetchSegment(context.Background(), srv.URL, "", file, 0, 99, io.Discard)
+			mu.Lock()
+			defer mu.Unlock()
+			// every retry waits at least half of the backoff
+			if waited, min := time.Since(began), time.Duration(len(requests)-1)*20*time.Millisecond; waited < min {
+				t.Errorf("%d attempts took %v, want at least %v", len(requests), waited, min)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("fetchSegment() error = %v, want prefix %q", err, tt.wantErr)
+				}
+				if len(requests) != tt.tries {
+					t.Errorf("%d requests, want %d", len(requests), tt.tries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"bytes=0-99", "bytes=10-99", "bytes=20-99"}; strings.Join(requests, " ") != strings.Join(want, " ") {
+				t.Errorf("requests = %v, want %v", requests, want)
+			}
+			got, err := os.ReadFile(file.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("segment = %q, want %q", got, data)
+			}
+		})
+	}
+}
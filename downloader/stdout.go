@@ -0,0 +1,59 @@
+package downloader
+
+// -O - : downloads streamed to stdout
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+)
+
+// stdoutName is the -O and -i value meaning stdout and stdin.
+const stdoutName = "-"
+
+// streamToStdout downloads fileURL straight to stdout, for piping into tar,
+// jq and the like. Messages and the progress bar go to stderr meanwhile, see
+// logger.ReserveStdout. There is no file to resume or delete, so a
+// --checksum mismatch only fails the run after the data has been written.
+func streamToStdout(ctx context.Context, fileURL string, ev *downloadEvent) error {
+	ev.Destination = stdoutName
+	req, err := newRequest(ctx, fileURL)
+	if err != nil {
+		return err
+	}
+	resp, err := doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ev.Status = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	writers := []io.Writer{os.Stdout, newBar(resp.ContentLength)}
+	var h hash.Hash
+	algo, digest := "", ""
+	if checksum != "" {
+		if algo, digest, err = parseChecksum(checksum); err != nil {
+			return err
+		}
+		h = hashAlgorithms[algo]()
+		writers = append(writers, h)
+	}
+
+	ev.Bytes, err = io.Copy(io.MultiWriter(writers...), limitReader(ctx, resp.Body))
+	if err != nil {
+		return fmt.Errorf("error writing to stdout: %v", err)
+	}
+	if h != nil {
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+			return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s", algo, fileURL, digest, actual)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package downloader
+
+// timestamping mode (-N) - only fetch files that changed on the server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// timestamping is set by -N / --timestamping
+var timestamping bool
+
+// errNotModified is returned when -N finds the local file up to date.
+var errNotModified = errors.New("server file no newer than local file; not retrieving")
+
+// addConditions makes req conditional on the local copy of target: the
+// server answers 304 unless its file is newer than ours or has another
+// ETag than the one stored by the last download.
+func addConditions(req *http.Request, target string) {
+	info, err := os.Stat(target)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	if st := loadResumeState(target); st.ETag != "" {
+		req.Header.Set("If-None-Match", st.ETag)
+	}
+}
+
+// upToDate reports whether a full response describes the file already at
+// target: not newer than the local mtime and of the same size. It catches
+// servers that ignore conditional requests.
+func upToDate(resp *http.Response, target string) bool {
+	info, err := os.Stat(target)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil || modified.After(info.ModTime()) {
+		return false
+	}
+	return resp.ContentLength < 0 || resp.ContentLength == info.Size()
+}
+
+// applyServerTime sets the mtime of a finished download to the
+// Last-Modified time the server sent for it, so the next -N run can
+// compare the two.
+func applyServerTime(target string) {
+	modified, err := http.ParseTime(loadResumeState(target).LastModified)
+	if err != nil {
+		return
+	}
+	os.Chtimes(target, time.Now(), modified)
+}
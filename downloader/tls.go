@@ -0,0 +1,163 @@
+package downloader
+
+// TLS configuration - CA bundles, client certificates, pinning
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wget/logger"
+)
+
+// errPinMismatch is returned when the server key matches none of the pins.
+var errPinMismatch = errors.New("server public key does not match --pinned-pubkey")
+
+// tlsVersions maps --tls-min-version values to crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// configureTLS builds the TLS settings of the shared transport.
+func configureTLS(flags map[string]string) error {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if value := flags["tls-min-version"]; value != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(value), "tlsv")]
+		if !ok {
+			return fmt.Errorf("invalid --tls-min-version %q, expected 1.0, 1.1, 1.2 or 1.3", value)
+		}
+		cfg.MinVersion = version
+	}
+
+	if flags["ca-certificate"] != "" || flags["ca-directory"] != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if path := flags["ca-certificate"]; path != "" {
+			if err := addCAFile(pool, path); err != nil {
+				return err
+			}
+		}
+		if dir := flags["ca-directory"]; dir != "" {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return fmt.Errorf("reading --ca-directory: %v", err)
+			}
+			for _, entry := range entries {
+				ext := strings.ToLower(filepath.Ext(entry.Name()))
+				if entry.IsDir() || (ext != ".pem" && ext != ".crt" && ext != ".cer" && ext != ".0") {
+					continue
+				}
+				if err := addCAFile(pool, filepath.Join(dir, entry.Name())); err != nil {
+					return err
+				}
+			}
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile := flags["certificate"]; certFile != "" {
+		keyFile := flags["private-key"]
+		if keyFile == "" {
+			keyFile = certFile // the key may live in the same PEM file
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	} else if flags["private-key"] != "" {
+		return fmt.Errorf("--private-key requires --certificate")
+	}
+
+	if flags["no-check-certificate"] != "" {
+		logger.Errorf("WARNING: certificate verification is disabled (--no-check-certificate)\n")
+		cfg.InsecureSkipVerify = true
+	}
+
+	if value := flags["pinned-pubkey"]; value != "" {
+		pins, err := parsePins(value)
+		if err != nil {
+			return err
+		}
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errPinMismatch
+			}
+			sum := sha256.Sum256(state.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			if !pins[base64.StdEncoding.EncodeToString(sum[:])] {
+				return errPinMismatch
+			}
+			return nil
+		}
+	}
+
+	transport.TLSClientConfig = cfg
+	return nil
+}
+
+// addCAFile adds every PEM certificate of path to pool.
+func addCAFile(pool *x509.CertPool, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading CA certificate: %v", err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return nil
+}
+
+// parsePins parses "sha256//<base64>" pins separated by ';'.
+func parsePins(value string) (map[string]bool, error) {
+	pins := map[string]bool{}
+	for _, pin := range strings.Split(value, ";") {
+		pin = strings.TrimSpace(pin)
+		hash, ok := strings.CutPrefix(pin, "sha256//")
+		if !ok {
+			return nil, fmt.Errorf("invalid pin %q, expected sha256//<base64 SPKI hash>", pin)
+		}
+		if raw, err := base64.StdEncoding.DecodeString(hash); err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %q, expected sha256//<base64 SPKI hash>", pin)
+		}
+		pins[hash] = true
+	}
+	return pins, nil
+}
+
+// explainTLSError adds a hint on how to fix certificate verification errors.
+func explainTLSError(err error) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidErr       x509.CertificateInvalidError
+		verifyErr        *tls.CertificateVerificationError
+	)
+	switch {
+	case errors.Is(err, errPinMismatch):
+		return err
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("%v (use --ca-certificate or --ca-directory to trust the issuing CA, or --no-check-certificate to skip verification)", err)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("%v (the certificate was issued for a different host name)", err)
+	case errors.As(err, &invalidErr):
+		if invalidErr.Reason == x509.Expired {
+			return fmt.Errorf("%v (the server certificate is expired or not yet valid, check the system clock)", err)
+		}
+		return fmt.Errorf("%v (the server certificate is not valid)", err)
+	case errors.As(err, &verifyErr):
+		return fmt.Errorf("%v (certificate verification failed)", err)
+	}
+	return err
+}
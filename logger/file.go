@@ -0,0 +1,77 @@
+package logger
+
+// log files for -o / -a and background mode, and exit hooks
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	// toFile is set once the log goes to a file instead of a terminal
+	toFile bool
+
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// OpenLog opens the log file at path, truncated unless appendTo is set (-a).
+func OpenLog(path string, appendTo bool) (*os.File, error) {
+	mode := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		mode = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, mode, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %v", err)
+	}
+	return file, nil
+}
+
+// SetLogFile sends the log to file. Progress bars are left out of it.
+func SetLogFile(file *os.File) {
+	SetOutput(file)
+	toFile = true
+}
+
+// ToFile reports whether the log goes to a file rather than a terminal.
+func ToFile() bool {
+	return toFile
+}
+
+// FreeLogName returns name, or name.1, name.2, ... whichever doesn't exist
+// yet, so a background run never overwrites the log of an earlier one.
+func FreeLogName(name string) string {
+	candidate := name
+	for n := 1; ; n++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s.%d", name, n)
+	}
+}
+
+// AtExit registers f to run when the program ends through Exit.
+func AtExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, f)
+}
+
+// RunExitHooks runs the AtExit functions, latest first, once.
+func RunExitHooks() {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// Exit runs the exit hooks and terminates with code.
+func Exit(code int) {
+	RunExitHooks()
+	os.Exit(code)
+}
@@ -1,45 +1,189 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"log"
-	"strings")
+	"sync"
+	"time"
+)
 
 var (
-	enableFileLogging  = false
-	MultipleFiles      bool
-	totalLog           []string
-	downloadBarStarted bool
+	mu sync.Mutex
+	// output is the log: stdout, or wget-log in background mode
+	output io.Writer = os.Stdout
+	// jsonFormat is set by --log-format json
+	jsonFormat bool
 )
 
-func SetLogToFile(log bool) {
-	enableFileLogging = log
+// Event is one step in the life of a download, written as a JSON line with
+// --log-format json.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Event       string    `json:"event"` // "done", "skipped", "error" or "redirect"
+	URL         string    `json:"url"`
+	Status      int       `json:"status,omitempty"`
+	Bytes       int64     `json:"bytes"`
+	Duration    float64   `json:"duration"` // seconds
+	Destination string    `json:"destination,omitempty"`
+	Location    string    `json:"location,omitempty"` // redirect target
+	Error       string    `json:"error,omitempty"`
 }
 
-// Log function logs the formatted string to the console or to a file depending on if the file logging is enabled.
-func Log(pattern string, a ...interface{}) {
-	formatted_string := fmt.Sprintf(pattern, a...)
-	if !enableFileLogging { // if file logging is not enabled, print the formatted string
-		fmt.Print(formatted_string)
-	} else {
-		totalLog = append(totalLog, formatted_string)
-		logToFile()
+// Level is how much the program tells about its work.
+type Level int
+
+const (
+	LevelQuiet   Level = iota // -q: nothing at all
+	LevelTerse                // -nv: one line per file, warnings and errors
+	LevelNormal               // the default
+	LevelVerbose              // -v: also robots.txt and other details
+	LevelDebug                // -d: also every request and response header
+)
+
+var level = LevelNormal
+
+// reservedStdout is set when stdout carries file content (-O -)
+var reservedStdout bool
+
+// Configure applies the output flags: --log-format, the verbosity flags and
+// -O -. When several verbosity flags are given the most verbose wins.
+func Configure(flags map[string]string) error {
+	if err := SetFormat(flags["log-format"]); err != nil {
+		return err
 	}
+	if flags["O"] == "-" {
+		// the file itself is written to stdout
+		ReserveStdout()
+	}
+	switch {
+	case flags["debug"] != "":
+		SetLevel(LevelDebug)
+	case flags["verbose"] != "":
+		SetLevel(LevelVerbose)
+	case flags["no-verbose"] != "":
+		SetLevel(LevelTerse)
+	case flags["quiet"] != "":
+		SetLevel(LevelQuiet)
+	}
+	return nil
 }
 
-func logToFile() {
-	if !enableFileLogging {
-		return
+// SetLevel sets how much is logged.
+func SetLevel(l Level) {
+	level = l
+}
+
+// Enabled reports whether messages of level l are logged.
+func Enabled(l Level) bool {
+	return level >= l
+}
+
+// SetFormat selects the log format, "text" (default) or "json".
+func SetFormat(format string) error {
+	switch format {
+	case "", "text":
+		jsonFormat = false
+	case "json":
+		jsonFormat = true
+	default:
+		return fmt.Errorf("invalid --log-format %q, expected 'text' or 'json'", format)
 	}
-	file, err := os.Create("wget-log")
-	if err != nil {
-		log.Fatalf("\nError creating log file! %s", err)
+	return nil
+}
+
+// JSON reports whether --log-format json is in effect.
+func JSON() bool {
+	return jsonFormat
+}
+
+// SetOutput sends the log to w.
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if reservedStdout && w == os.Stdout {
+		w = os.Stderr
 	}
-	defer file.Close()
+	output = w
+}
 
-	_, err = file.WriteString(strings.Join(totalLog, ""))
+// ReserveStdout keeps every message off stdout, which then carries nothing
+// but the downloaded data. A log on stdout moves to stderr.
+func ReserveStdout() {
+	mu.Lock()
+	defer mu.Unlock()
+	reservedStdout = true
+	if output == os.Stdout {
+		output = os.Stderr
+	}
+}
+
+// Writer returns where human readable messages go: the log itself, or
+// stderr when the log is reserved for JSON events.
+func Writer() io.Writer {
+	if jsonFormat {
+		return os.Stderr
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return output
+}
+
+// LevelWriter returns Writer when messages of level l are logged and
+// io.Discard otherwise.
+func LevelWriter(l Level) io.Writer {
+	if !Enabled(l) {
+		return io.Discard
+	}
+	return Writer()
+}
+
+// Printf writes a message of the default level.
+func Printf(format string, a ...any) {
+	fmt.Fprintf(LevelWriter(LevelNormal), format, a...)
+}
+
+// Println writes a message of the default level followed by a newline.
+func Println(a ...any) {
+	fmt.Fprintln(LevelWriter(LevelNormal), a...)
+}
+
+// Infof writes a message that -nv keeps, such as the one line it prints
+// per file.
+func Infof(format string, a ...any) {
+	fmt.Fprintf(LevelWriter(LevelTerse), format, a...)
+}
+
+// Errorf writes a warning or error, silenced only by -q.
+func Errorf(format string, a ...any) {
+	fmt.Fprintf(LevelWriter(LevelTerse), format, a...)
+}
+
+// Verbosef writes a message shown with -v and -d.
+func Verbosef(format string, a ...any) {
+	fmt.Fprintf(LevelWriter(LevelVerbose), format, a...)
+}
+
+// Debugf writes a message shown with -d.
+func Debugf(format string, a ...any) {
+	fmt.Fprintf(LevelWriter(LevelDebug), format, a...)
+}
+
+// Emit writes ev as one JSON line when --log-format json is set. Lines of
+// concurrent downloads never interleave.
+func Emit(ev Event) {
+	if !jsonFormat {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	line, err := json.Marshal(ev)
 	if err != nil {
-		log.Fatalf("\nError Eriting to log file! %s", err)
+		return
 	}
-}
\ No newline at end of file
+	mu.Lock()
+	defer mu.Unlock()
+	output.Write(append(line, '\n'))
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"wget/config"
 	"wget/downloader"
+	"wget/logger"
 	"wget/mirrorer"
 	"wget/utils"
 	"wget/web"
@@ -14,9 +14,24 @@ func main() {
 	//get the flags entered in
 	flags, flagProvided, startweb, url2, err := config.ParseFlags()
 	if err != nil {
-		fmt.Println("Error parsing flags:", err)
-		return
+		logger.Errorf("Error parsing flags: %v\n", err)
+		logger.Exit(2)
 	}
+	if err := logger.Configure(flags); err != nil {
+		logger.Errorf("Error parsing flags: %v\n", err)
+		logger.Exit(2)
+	}
+	// settings that load files (cookies, certificates, checksums) fail here too
+	if err := downloader.Configure(flags); err != nil {
+		logger.Errorf("%v\n", err)
+		logger.Exit(1)
+	}
+	if err := setupLog(flags); err != nil {
+		logger.Errorf("%v\n", err)
+		logger.Exit(1)
+	}
+	defer logger.RunExitHooks()
+	failed := false
 	if startweb {
 		web.StartWebServer()
 	} else {
@@ -24,7 +39,6 @@ func main() {
 		if flagProvided {
 			if flags["mirror"] != "" {
 				mirrorer.ParseMirrorFlag(flags)
-				return
 			} else {
 				downloader.HandleDownloadWithFlags(url2, flags)
 			}
@@ -32,8 +46,8 @@ func main() {
 			//get a name for the download and call the download function
 			output, err := utils.MakeAName(url)
 			if err != nil {
-				fmt.Println("Error making a name for the download:", err)
-				return
+				logger.Errorf("Error making a name for the download: %v\n", err)
+				logger.Exit(1)
 			}
 			downloader.SetFileName(output)
 
@@ -41,8 +55,18 @@ func main() {
 			url = utils.EnsureScheme(url)
 			_, err = downloader.DownloadFile(url, false)
 			if err != nil {
-				fmt.Println("Error downloading the file:", err)
+				logger.Errorf("Error downloading the file: %v\n", err)
+				failed = true
 			}
 		}
 	}
+
+	// keep the session for the next run when --save-cookies is set
+	if err := downloader.SaveCookies(); err != nil {
+		logger.Errorf("%v\n", err)
+		failed = true
+	}
+	if failed {
+		logger.Exit(1)
+	}
 }
\ No newline at end of file
@@ -2,15 +2,16 @@
 package mirrorer
 
 import (
-	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"wget/downloader"
+	"wget/logger"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -19,14 +20,74 @@ var (
 	excludeExtsList = []string{}
 	excludeDirsList = []string{}
 	convertLinks    = false
+	maxLevel        = 0 // 0 means no depth limit
+	spanHosts       = false
 	baseURL         *url.URL
 )
 
-// DownloaderWrapper wraps the file download logic for reuse.
+var cssURLMatcher = regexp.MustCompile(`url\(['"]?(.*?)['"]?\)`)
+
+// crawlItem is a page waiting in the crawl queue.
+type crawlItem struct {
+	url   *url.URL
+	depth int
+}
+
+// crawler keeps the state of one mirror run: the pages left to visit and
+// every URL already claimed, so nothing is downloaded twice.
+type crawler struct {
+	mu      sync.Mutex
+	visited map[string]bool
+	queue   []crawlItem
+}
+
+// claim marks u as visited and reports whether it was new.
+func (c *crawler) claim(u *url.URL) bool {
+	key := visitKey(u)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.visited[key] {
+		return false
+	}
+	c.visited[key] = true
+	return true
+}
+
+// enqueue schedules a page for crawling if it was not seen before.
+func (c *crawler) enqueue(u *url.URL, depth int) {
+	if c.claim(u) {
+		c.mu.Lock()
+		c.queue = append(c.queue, crawlItem{url: u, depth: depth})
+		c.mu.Unlock()
+	}
+}
+
+// visitKey normalizes a URL for the visited set. URLs saved to the same
+// local file, such as /site/ and /site/index.html, share a key, so a page
+// is never fetched again over its already patched copy.
+func visitKey(u *url.URL) string {
+	clean := *u
+	clean.Fragment = ""
+	if clean.Path == "" {
+		clean.Path = "/"
+	}
+	if local, err := downloader.LocalPath(clean.String(), true); err == nil {
+		return local
+	}
+	return clean.String()
+}
+
+// DownloaderWrapper wraps the file download logic for reuse. Every download
+// goes through the downloader's host scheduler so a page with dozens of
+// assets doesn't open dozens of connections to the same server.
 func DownloaderWrapper(urlStr string) *os.File {
-	file, err := downloader.DownloadFile(urlStr, true)
+	if u, err := url.Parse(urlStr); err == nil {
+		release := downloader.AcquireHost(u.Host)
+		defer release()
+	}
+	file, err := downloader.MirrorFile(urlStr, baseURL.Host)
 	if err != nil {
-		fmt.Printf("Error downloading file: %v\n", err)
+		logger.Errorf("Error downloading file: %v\n", err)
 		return nil
 	}
 	return file
@@ -47,113 +108,191 @@ func SetConvertLinks(convert bool) {
 	convertLinks = convert
 }
 
-// Mirror downloads and patches a given HTML page and its assets.
+// SetMaxLevel sets how many links deep the crawl goes, 0 for no limit.
+func SetMaxLevel(level int) {
+	maxLevel = level
+}
+
+// SetSpanHosts allows following links to other hosts than the start page.
+func SetSpanHosts(span bool) {
+	spanHosts = span
+}
+
+// Mirror downloads the given page and recursively crawls the pages it links
+// to, patching each HTML page and downloading its assets.
 func Mirror(url *url.URL) {
 	baseURL = url
-	file := DownloaderWrapper(url.String())
-	if file == nil {
-		return
+	c := &crawler{visited: map[string]bool{}}
+	c.enqueue(url, 0)
+
+	for len(c.queue) > 0 {
+		item := c.queue[0]
+		c.queue = c.queue[1:]
+
+		file := DownloaderWrapper(item.url.String())
+		if file == nil {
+			continue
+		}
+		if isHTML(file) {
+			file.Seek(0, 0)
+			c.patchLinks(file, item)
+		}
+		file.Close()
 	}
-	defer file.Close()
-	file.Seek(0, 0)
-	patchLinks(file)
-	fmt.Println()
+	logger.Println()
 }
 
-// processUrl resolves relative URLs using baseURL.
-func processUrl(urlStr string) string {
-	if baseURL == nil || urlStr == "" {
-		return urlStr
+// isHTML sniffs the start of a downloaded file for HTML content.
+func isHTML(file *os.File) bool {
+	ext := strings.ToLower(filepath.Ext(file.Name()))
+	if ext == ".html" || ext == ".htm" {
+		return true
 	}
+	buf := make([]byte, 512)
+	n, _ := file.ReadAt(buf, 0)
+	return strings.HasPrefix(http.DetectContentType(buf[:n]), "text/html")
+}
 
-	u, err := url.Parse(urlStr)
-	if err != nil || u.IsAbs() {
-		return urlStr
+// processUrl resolves a link found on page into an absolute URL. Links that
+// can't be downloaded (fragments, mailto:, javascript:, data:) return nil.
+func processUrl(page *url.URL, link string) *url.URL {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return nil
 	}
-
-	if strings.HasPrefix(urlStr, "/") || strings.HasPrefix(urlStr, "./") {
-		return fmt.Sprintf("%s://%s%s", baseURL.Scheme, baseURL.Host, path.Join(baseURL.Path, urlStr))
+	ref, err := url.Parse(link)
+	if err != nil {
+		return nil
 	}
-
-	if baseURL.Path == "/" {
-		return fmt.Sprintf("%s://%s/%s", baseURL.Scheme, baseURL.Host, urlStr)
+	resolved := page.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return nil
 	}
-
-	return fmt.Sprintf("%s://%s%s/%s", baseURL.Scheme, baseURL.Host, baseURL.Path, urlStr)
+	resolved.Fragment = ""
+	return resolved
 }
 
 // linkAllowed checks if a link should be excluded.
-func linkAllowed(link string) bool {
+func linkAllowed(link *url.URL) bool {
 	for _, ext := range excludeExtsList {
-		if strings.HasSuffix(link, "."+ext) {
+		if strings.HasSuffix(link.Path, "."+ext) {
 			return false
 		}
 	}
 	for _, dir := range excludeDirsList {
-		if strings.HasPrefix(strings.TrimPrefix(link, "."), dir) {
+		if strings.HasPrefix(link.Path, "/"+strings.TrimPrefix(strings.TrimPrefix(dir, "."), "/")) {
 			return false
 		}
 	}
 	return true
 }
 
-// patchLinks updates resource links in the HTML and downloads them.
-func patchLinks(file *os.File) {
+// inScope reports whether a linked page should be crawled from a page at depth.
+func inScope(link *url.URL, depth int) bool {
+	if maxLevel > 0 && depth+1 > maxLevel {
+		return false
+	}
+	return spanHosts || link.Host == baseURL.Host
+}
+
+// relativeLink returns the path of target relative to the page being patched.
+func relativeLink(pageFile, target string) string {
+	relPath, err := filepath.Rel(filepath.Dir(pageFile), target)
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// patchLinks downloads the assets of an HTML page, queues the pages it links
+// to and, with --convert-links, rewrites the links to the local copies.
+func (c *crawler) patchLinks(file *os.File, page crawlItem) {
 	doc, err := goquery.NewDocumentFromReader(file)
 	if err != nil {
 		return
 	}
 
+	// relative links are relative to where redirects took the page
+	base := page.url
+	if final, err := url.Parse(downloader.FinalURL(page.url.String())); err == nil {
+		base = final
+	}
+
 	wg := sync.WaitGroup{}
 
+	// fetchAsset downloads a page requisite once and returns its local path.
+	fetchAsset := func(link *url.URL) (string, bool) {
+		if c.claim(link) {
+			linkFile := DownloaderWrapper(link.String())
+			if linkFile == nil {
+				return "", false
+			}
+			linkFile.Close()
+			return linkFile.Name(), true
+		}
+		local, err := downloader.LocalPath(link.String(), true)
+		return local, err == nil
+	}
+
 	downloadAndPatch := func(sel *goquery.Selection, attr string) {
-		link, exists := sel.Attr(attr)
-		if !exists || !linkAllowed(link) {
+		raw, exists := sel.Attr(attr)
+		if !exists {
+			return
+		}
+		link := processUrl(base, raw)
+		if link == nil || !linkAllowed(link) {
 			return
 		}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			linkFile := DownloaderWrapper(processUrl(link))
-			if linkFile == nil {
+			if !robotsAllowed(link) {
 				return
 			}
-			defer linkFile.Close()
-			if convertLinks {
-				relPath, err := filepath.Rel(filepath.Dir(file.Name()), linkFile.Name())
-				if err != nil {
-					sel.SetAttr(attr, linkFile.Name())
-				} else {
-					sel.SetAttr(attr, relPath)
-				}
+			local, ok := fetchAsset(link)
+			if ok && convertLinks {
+				sel.SetAttr(attr, relativeLink(file.Name(), local))
 			}
 		}()
 	}
 
-	doc.Find("a").Each(func(i int, s *goquery.Selection) { downloadAndPatch(s, "href") })
+	// honor <meta name="robots" content="nofollow"> and rel="nofollow"
+	noFollow := respectRobots && metaNoFollow(doc)
+
+	followLink := func(sel *goquery.Selection) {
+		raw, exists := sel.Attr("href")
+		if !exists || noFollow || (respectRobots && relNoFollow(sel)) {
+			return
+		}
+		link := processUrl(base, raw)
+		if link == nil || !linkAllowed(link) || !inScope(link, page.depth) || !robotsAllowed(link) {
+			return
+		}
+		c.enqueue(link, page.depth+1)
+		if convertLinks {
+			if local, err := downloader.LocalPath(link.String(), true); err == nil {
+				sel.SetAttr("href", relativeLink(file.Name(), local))
+			}
+		}
+	}
+
+	doc.Find("a").Each(func(i int, s *goquery.Selection) { followLink(s) })
 	doc.Find("img").Each(func(i int, s *goquery.Selection) { downloadAndPatch(s, "src") })
 	doc.Find("link").Each(func(i int, s *goquery.Selection) { downloadAndPatch(s, "href") })
 	doc.Find("script").Each(func(i int, s *goquery.Selection) { downloadAndPatch(s, "src") })
 
 	// Handle inline CSS background images
 	doc.Find("style").Each(func(i int, s *goquery.Selection) {
-		matcher := regexp.MustCompile(`url\(['"]?(.*?)['"]?\)`)
 		css := s.Text()
-		css = matcher.ReplaceAllStringFunc(css, func(match string) string {
-			url := matcher.FindStringSubmatch(match)[1]
-			if !linkAllowed("url") {
-				return match
-			}
-			linkFile := DownloaderWrapper(processUrl(url))
-			if linkFile == nil {
+		css = cssURLMatcher.ReplaceAllStringFunc(css, func(match string) string {
+			raw := cssURLMatcher.FindStringSubmatch(match)[1]
+			link := processUrl(base, raw)
+			if link == nil || !linkAllowed(link) || !robotsAllowed(link) {
 				return match
 			}
-			defer linkFile.Close()
-			if convertLinks {
-				relPath, err := filepath.Rel(filepath.Dir(file.Name()), linkFile.Name())
-				if err == nil {
-					return strings.Replace(match, url, relPath, 1)
-				}
+			local, ok := fetchAsset(link)
+			if ok && convertLinks {
+				return strings.Replace(match, raw, relativeLink(file.Name(), local), 1)
 			}
 			return match
 		})
@@ -167,7 +306,7 @@ func patchLinks(file *os.File) {
 	if err != nil {
 		return
 	}
-	file.Seek(0, 0)
+	file.Seek(0, io.SeekStart)
 	file.Truncate(0)
 	file.WriteString(html)
 }
@@ -3,9 +3,11 @@ package mirrorer
 import (
 	"fmt"
 	"net/url"
-	"os"
 	"strings"
 	"flag"
+	"strconv"
+	"wget/logger"
+	"wget/utils"
 )
 // code when mirror flag is set
 func ParseMirrorFlag(flags map[string]string) {
@@ -32,12 +34,57 @@ func ParseMirrorFlag(flags map[string]string) {
 		SetConvertLinks(true)
 	}
 
+	if flags["level"] != "" {
+		flags["l"] = flags["level"]
+	}
+	if flags["l"] != "" {
+		level, err := parseLevel(flags["l"])
+		if err != nil {
+			logger.Errorf("%v\n", err)
+			logger.Exit(1)
+		}
+		SetMaxLevel(level)
+	}
+
+	if flags["span-hosts"] != "" {
+		SetSpanHosts(true)
+	}
+
+	// robots.txt groups are matched against the agent we identify as
+	if flags["user-agent"] != "" {
+		SetRobotsAgent(flags["user-agent"])
+	}
+
+	// -e robots=off, checked by config.ParseFlags
+	switch flags["robots"] {
+	case "on":
+		SetRespectRobots(true)
+	case "off":
+		SetRespectRobots(false)
+	}
+
 	if flag.NArg() == 0 {
-		fmt.Println("Missing URL")
-		os.Exit(1)
+		logger.Errorf("Missing URL\n")
+		logger.Exit(1)
 	}
 
-	url, _ := url.Parse(flag.Arg(0))
-	println("Mirroring URL:", url.String())
+	url, err := url.Parse(utils.EnsureScheme(flag.Arg(0)))
+	if err != nil {
+		logger.Errorf("Invalid URL: %v\n", err)
+		logger.Exit(1)
+	}
+	logger.Printf("Mirroring URL: %s\n", url.String())
 	Mirror(url)
 }
+
+// parseLevel parses the --level value, "inf" or 0 meaning no limit.
+func parseLevel(value string) (int, error) {
+	if value == "inf" {
+		return 0, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 {
+		return 0, fmt.Errorf("invalid --level value %q", value)
+	}
+	return level, nil
+}
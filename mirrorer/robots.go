@@ -0,0 +1,252 @@
+package mirrorer
+
+// robots.txt and meta robots support for the crawler
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wget/downloader"
+	"wget/logger"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	respectRobots = true
+	robotsAgent   = "get-with-a-w"
+)
+
+// SetRespectRobots turns robots.txt and meta robots handling on or off.
+func SetRespectRobots(respect bool) {
+	respectRobots = respect
+}
+
+// SetRobotsAgent sets the user agent matched against robots.txt groups.
+func SetRobotsAgent(agent string) {
+	robotsAgent = agent
+}
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsRules are the rules of the group that applies to our user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsEntry caches the rules of one host, fetched at most once.
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+var (
+	robotsMu    sync.Mutex
+	robotsCache = map[string]*robotsEntry{}
+)
+
+// robotsAllowed reports whether robots.txt of the link's host lets us fetch it.
+func robotsAllowed(link *url.URL) bool {
+	if !respectRobots {
+		return true
+	}
+	key := link.Scheme + "://" + link.Host
+
+	robotsMu.Lock()
+	entry, ok := robotsCache[key]
+	if !ok {
+		entry = &robotsEntry{}
+		robotsCache[key] = entry
+	}
+	robotsMu.Unlock()
+
+	entry.once.Do(func() {
+		entry.rules = fetchRobots(key)
+		if entry.rules.crawlDelay > 0 {
+			downloader.SetHostDelay(link.Host, entry.rules.crawlDelay)
+		}
+	})
+
+	path := link.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if link.RawQuery != "" {
+		path += "?" + link.RawQuery
+	}
+	return entry.rules.allowed(path)
+}
+
+// fetchRobots downloads and parses robots.txt for a scheme://host. Missing
+// or unreachable files allow everything.
+func fetchRobots(origin string) *robotsRules {
+	resp, err := downloader.Get(origin+"/robots.txt", baseURL.Host)
+	if err != nil {
+		return &robotsRules{}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &robotsRules{}
+	}
+	logger.Verbosef("Loaded robots.txt for %s\n", origin)
+	return parseRobots(io.LimitReader(resp.Body, 512*1024), robotsAgent)
+}
+
+// parseRobots keeps the group whose User-agent best matches agent, falling
+// back to the "*" group.
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+	if i := strings.IndexByte(agent, '/'); i >= 0 {
+		agent = agent[:i]
+	}
+
+	var (
+		specific, wildcard *robotsRules
+		current            []*robotsRules
+		inRules            bool
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// a User-agent line after rules starts a new group
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			ua := strings.ToLower(value)
+			switch {
+			case ua == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			case ua != "" && strings.Contains(agent, ua):
+				if specific == nil {
+					specific = &robotsRules{}
+				}
+				current = append(current, specific)
+			default:
+				current = append(current, &robotsRules{}) // someone else's group
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue // an empty Disallow allows everything
+			}
+			for _, group := range current {
+				group.rules = append(group.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			inRules = true
+			secs, err := strconv.ParseFloat(value, 64)
+			if err != nil || secs < 0 {
+				continue
+			}
+			for _, group := range current {
+				group.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	switch {
+	case specific != nil:
+		return specific
+	case wildcard != nil:
+		return wildcard
+	}
+	return &robotsRules{}
+}
+
+// allowed applies the longest matching rule, Allow winning ties.
+func (r *robotsRules) allowed(path string) bool {
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || (len(rule.pattern) == best && rule.allow) {
+			best, allow = len(rule.pattern), rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches path against a robots.txt pattern supporting the '*'
+// wildcard and a trailing '$' anchor.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	switch {
+	case !anchored:
+		return true
+	case len(parts) == 1:
+		return rest == ""
+	}
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
+
+// metaNoFollow reports whether the page asks crawlers not to follow its links.
+func metaNoFollow(doc *goquery.Document) bool {
+	nofollow := false
+	doc.Find("meta[name]").Each(func(i int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		name = strings.ToLower(name)
+		if name != "robots" && name != strings.ToLower(robotsAgent) {
+			return
+		}
+		content, _ := s.Attr("content")
+		for _, directive := range strings.Split(strings.ToLower(content), ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "nofollow" || directive == "none" {
+				nofollow = true
+			}
+		}
+	})
+	return nofollow
+}
+
+// relNoFollow reports whether a link carries rel="nofollow".
+func relNoFollow(sel *goquery.Selection) bool {
+	rel, _ := sel.Attr("rel")
+	for _, token := range strings.Fields(strings.ToLower(rel)) {
+		if token == "nofollow" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package mirrorer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/private", "/private/page.html", true},
+		{"/private", "/privateer", true},
+		{"/private", "/public", false},
+		{"/*.php", "/index.php", true},
+		{"/*.php", "/dir/index.php?x=1", true},
+		{"/*.php", "/index.html", false},
+		{"/*.php$", "/index.php", true},
+		{"/*.php$", "/index.php?x=1", false},
+		{"/*.php$", "/a.php.php", true},
+		{"/page$", "/page", true},
+		{"/page$", "/page/", false},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+		{"/a*$", "/a/anything", true},
+		{"/ab*b$", "/ab", false},
+		{"/a*ba$", "/aba", true},
+		{"*", "/x", true},
+		{"/x*", "/y", false},
+	}
+	for _, tt := range tests {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	const robots = `# example
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Crawl-delay: 2
+
+User-agent: get-with-a-w
+User-agent: otherbot
+Disallow: /no-gwaw
+Allow: /no-gwaw/*.css$
+Crawl-delay: 0.5
+
+User-agent: evilbot
+Disallow: /
+`
+	tests := []struct {
+		name  string
+		agent string
+		delay time.Duration
+		paths map[string]bool
+	}{
+		{
+			name: "specific group", agent: "get-with-a-w/1.0", delay: 500 * time.Millisecond,
+			paths: map[string]bool{
+				"/private":            true, // only the * group disallows it
+				"/no-gwaw/page.html":  false,
+				"/no-gwaw/style.css":  true,
+				"/no-gwaw/style.css?": false,
+				"/":                   true,
+			},
+		},
+		{
+			name: "wildcard group", agent: "somebot", delay: 2 * time.Second,
+			paths: map[string]bool{
+				"/private/x":      false,
+				"/private/open/x": true, // longer Allow wins
+				"/no-gwaw":        true,
+			},
+		},
+		{
+			name: "disallow all", agent: "EvilBot", delay: 0,
+			paths: map[string]bool{"/": false, "/anything": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(robots), tt.agent)
+			if rules.crawlDelay != tt.delay {
+				t.Errorf("crawlDelay = %v, want %v", rules.crawlDelay, tt.delay)
+			}
+			for path, want := range tt.paths {
+				if got := rules.allowed(path); got != want {
+					t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRobotsEdgeCases(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		path    string
+		allowed bool
+	}{
+		{"empty file", "", "/x", true},
+		{"empty disallow allows all", "User-agent: *\nDisallow:\n", "/x", true},
+		{"tie goes to allow", "User-agent: *\nDisallow: /x\nAllow: /x\n", "/x", true},
+		{"comments and case", "USER-AGENT: * # all\nDISALLOW: /x # no\n", "/x/y", false},
+		{"rules before any agent are ignored", "Disallow: /x\n", "/x", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRobots(strings.NewReader(tt.robots), "get-with-a-w").allowed(tt.path); got != tt.allowed {
+				t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.allowed)
+			}
+		})
+	}
+}
@@ -36,21 +36,82 @@ func EnsureScheme(urlStr string) string {
 func DisplayHelp() {
     fmt.Println(`Usage: go run . [options] <URL>
 Options:
-  -B                  Run download in background and output to 'wget-log'.
-  -O <filename>       Download as a different filename.
+  --config <file>     Read options from a TOML config file.
+  -B                  Run in the background, logging to 'wget-log' (or wget-log.1, ...).
+  -o, --output-file <f>    Write the log to a file.
+  -a, --append-output <f>  Append the log to a file.
+  --pid-file <file>   Where -B writes the background pid (default <log>.pid).
+  -O <filename>       Download as a different filename, '-' for stdout.
   -P <path>           Path where the file will be saved.
+  -q, --quiet         Print nothing.
+  -nv, --no-verbose   Print one line per file, warnings and errors.
+  -v, --verbose       Print more details (e.g. robots.txt handling).
+  -d, --debug         Also print every request and response header.
+  --log-format <fmt>  Log as 'text' (default) or 'json' lines.
   --rate-limit <rate> Limit the download rate (e.g., 500k, 2M).
-  -i <file>           Download multiple files listed in a file.
+  -i <file>           Download multiple files listed in a file, '-' for stdin.
+  --input-format <f>  Format of the -i file: auto (default), plain, jsonl or csv.
+  --jobs <n>          Parallel downloads for -i (default 4).
+  --host-jobs <n>     Parallel downloads per host, 0 for no cap (default 4).
+  --wait <secs>       Pause between requests to the same host.
+  --random-wait       Vary --wait between 0.5 and 1.5 times its value.
+  --host-rate <n>     Maximum requests per second to the same host.
   --mirror            Download an entire website for offline viewing.
   -R <types>          Reject files of specified types (e.g., jpg, gif), used with --mirror.
   -X <paths>          Exclude certain paths from being downloaded, used with --mirror.
   --convert-links     Convert links for offline viewing, used with --mirror.
+  -l, --level <n>     Maximum link depth for --mirror, 'inf' for unlimited (default).
+  -H, --span-hosts    Let --mirror crawl pages on other hosts.
+  -e <command>        Execute a command, e.g. 'robots=off' or 'user_agent=name'.
+  --header <line>     Add a 'Name: value' header to every request (repeatable).
+  -U, --user-agent <agent>  Identify as agent instead of get-with-a-w/1.0.
+  --referer <url>     Send a Referer header.
+  --method <method>   Use this HTTP method for downloads.
+  --post-data <data>  POST the given string.
+  --post-file <file>  POST the contents of a file.
+  --body-file <file>  Send the contents of a file as the body of --method.
+  --user <name>       User name for Basic or Digest authentication.
+  --password <pass>   Password for --user.
+  --ask-password      Prompt for the --user password.
+  --bearer-token <t>  Send a bearer token to the requested host.
+  --load-cookies <f>  Load cookies from a Netscape cookies.txt file.
+  --save-cookies <f>  Save cookies to a Netscape cookies.txt file.
+  --keep-session-cookies  Also save session cookies.
+  --proxy <url>       Use an http://, https:// or socks5:// proxy.
+  --no-proxy <list>   Hosts, domains or CIDRs that bypass the proxy.
+  --proxy-user <name> User name for the proxy.
+  --proxy-password <p> Password for the proxy.
+  --ca-certificate <f> Trust the CA certificates in a PEM file.
+  --ca-directory <d>  Trust the PEM CA certificates in a directory.
+  --certificate <f>   Client certificate for mutual TLS.
+  --private-key <f>   Private key of --certificate.
+  --no-check-certificate  Don't verify the server certificate.
+  --tls-min-version <v>   Minimum TLS version (default 1.2).
+  --pinned-pubkey <pins>  Require a 'sha256//<base64>' SPKI pin.
+  --checksum <a:hex>  Verify the download, e.g. sha256:<hex>.
+  --checksum-file <f> Verify downloads against a SHA256SUMS style file.
+  --manifest <file>   Write the sha256 of every downloaded file.
+  --content-disposition  Name files as the server's Content-Disposition says.
+  -E, --adjust-extension Add the extension matching the Content-Type.
+  --max-redirect <n>  Follow at most n redirects per request (default 10).
+  --no-redirect       Don't follow redirects.
+  --trust-server-names  Name files after the URL at the end of the redirects.
+  --allow-https-downgrade  Follow redirects from https to http.
+  -nc, --no-clobber   Don't overwrite existing files.
+  --backups <n>       Keep n older versions as file.1 ... file.n.
+  -N, --timestamping  Only download files newer than the local copy.
+  -c, --continue      Resume a partially-downloaded file.
+  --tries <n>         Attempts per request, 0 for unlimited (default 3).
+  --retry-on <list>   Failures worth retrying (default net,408,429,5xx).
+  --waitretry <secs>  Maximum backoff between retries (default 10).
+  --segments <n>      Fetch one large file over n parallel connections.
 
 Examples:
   go run . https://example.com/file.zip
   go run . -O myfile.zip https://example.com/file.zip
   go run . --rate-limit=1M https://example.com/bigfile.zip
   go run . --mirror --convert-links https://example.com
+  go run . -c https://example.com/bigfile.iso
 
 Use 'man wget' for more information on wget features.`)
 }
\ No newline at end of file
@@ -1,11 +1,11 @@
 package web
 
 import (
-	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"wget/downloader"
+	"wget/logger"
 
 	"github.com/gin-gonic/gin"
 )
@@ -57,7 +57,7 @@ func StartWebServer() {
 func getDownloadsPath() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Println("Error getting home directory:", err)
+		logger.Errorf("Error getting home directory: %v\n", err)
 		return "./" // Fallback to current directory if the home directory can't be determined
 	}
 	// Directly join the Downloads directory to the home directory